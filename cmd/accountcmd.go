@@ -0,0 +1,283 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/polynetwork/bsc-relayer/tools"
+	"github.com/polynetwork/poly/common/password"
+	"github.com/urfave/cli"
+)
+
+var (
+	KeyFileFlag = cli.StringFlag{
+		Name:  "keyfile",
+		Usage: "file holding the hex private key to import, asked for if not set",
+	}
+
+	MinAmountFlag = cli.Uint64Flag{
+		Name:  "min",
+		Usage: "transfers below this amount in wei are left out",
+	}
+
+	DryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "print the transfers without sending them",
+	}
+
+	AccountsCommand = cli.Command{
+		Name:  "accounts",
+		Usage: "Manage the relayer accounts under KeyStorePath",
+		Subcommands: []cli.Command{
+			{
+				Name:   "new",
+				Usage:  "Create a new encrypted account",
+				Action: newAccount,
+			},
+			{
+				Name:   "import",
+				Usage:  "Import a raw private key as a new encrypted account",
+				Flags:  []cli.Flag{KeyFileFlag},
+				Action: importAccount,
+			},
+			{
+				Name:   "list",
+				Usage:  "List the accounts with their balances and nonces",
+				Action: listAccounts,
+			},
+			{
+				Name:   "verify",
+				Usage:  "Check the passwords of KeyStorePwdSet, or of the given account",
+				Flags:  []cli.Flag{AccountFlag},
+				Action: verifyAccounts,
+			},
+			{
+				Name:   "rebalance",
+				Usage:  "Spread the BNB of the relayer accounts evenly, the relayer must be stopped",
+				Flags:  []cli.Flag{MinAmountFlag, DryRunFlag},
+				Action: rebalanceAccounts,
+			},
+		},
+	}
+)
+
+func newAccount(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Password of the new account.")
+	pwd, err := password.GetConfirmedPassword()
+	if err != nil {
+		return err
+	}
+	acc, err := tools.OpenEthKeyStore(servConfig.BSCConfig.KeyStorePath, nil).NewAccount(string(pwd))
+	if err != nil {
+		return err
+	}
+	printNewAccount(acc)
+	return nil
+}
+
+func importAccount(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	var raw []byte
+	if file := ctx.String(GetFlagName(KeyFileFlag)); file != "" {
+		if raw, err = ioutil.ReadFile(file); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print("Private key. ")
+		if raw, err = password.GetPassword(); err != nil {
+			return err
+		}
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(string(raw)), "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid private key: %v", err)
+	}
+	fmt.Println("Password of the imported account.")
+	pwd, err := password.GetConfirmedPassword()
+	if err != nil {
+		return err
+	}
+	acc, err := tools.OpenEthKeyStore(servConfig.BSCConfig.KeyStorePath, nil).ImportKey(key, string(pwd))
+	if err != nil {
+		return err
+	}
+	printNewAccount(acc)
+	return nil
+}
+
+func printNewAccount(acc accounts.Account) {
+	fmt.Printf("account %s saved to %s\n", acc.Address.Hex(), acc.URL.Path)
+	fmt.Println("add its password to BSCConfig.KeyStorePwdSet or type it at startup")
+}
+
+func listAccounts(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	client, chainId, err := dialBSC(servConfig)
+	if err != nil {
+		return err
+	}
+	ks, err := newSigner(servConfig, chainId)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-44s %24s %8s %8s\n", "account", "balance (wei)", "nonce", "pending")
+	for _, acc := range ks.GetAccounts() {
+		balance, err := client.BalanceAt(context.Background(), acc.Address, nil)
+		if err != nil {
+			return err
+		}
+		nonce, err := client.NonceAt(context.Background(), acc.Address, nil)
+		if err != nil {
+			return err
+		}
+		pending, err := client.PendingNonceAt(context.Background(), acc.Address)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-44s %24s %8d %8d\n", acc.Address.Hex(), balance.String(), nonce, pending)
+	}
+	return nil
+}
+
+func verifyAccounts(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	ks := tools.OpenEthKeyStore(servConfig.BSCConfig.KeyStorePath, nil)
+	address := ctx.String(GetFlagName(AccountFlag))
+	failed := 0
+	for _, acc := range ks.GetAccounts() {
+		if address != "" && !strings.EqualFold(acc.Address.Hex(), address) {
+			continue
+		}
+		pwd, ok := servConfig.BSCConfig.KeyStorePwdSet[strings.ToLower(acc.Address.Hex())]
+		if !ok {
+			fmt.Printf("For address %s. ", acc.Address.Hex())
+			raw, err := password.GetPassword()
+			if err != nil {
+				return err
+			}
+			pwd = string(raw)
+		}
+		if err := ks.TestPwd(acc, pwd); err != nil {
+			failed++
+			fmt.Printf("%s: wrong password: %v\n", acc.Address.Hex(), err)
+			continue
+		}
+		fmt.Printf("%s: ok\n", acc.Address.Hex())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d account(s) failed", failed)
+	}
+	return nil
+}
+
+func rebalanceAccounts(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	client, chainId, err := dialBSC(servConfig)
+	if err != nil {
+		return err
+	}
+	ks, err := newSigner(servConfig, chainId)
+	if err != nil {
+		return err
+	}
+	accArr := ks.GetAccounts()
+	addrs := make([]common.Address, len(accArr))
+	balances := make([]*big.Int, len(accArr))
+	for i, acc := range accArr {
+		addrs[i] = acc.Address
+		if balances[i], err = client.BalanceAt(context.Background(), acc.Address, nil); err != nil {
+			return err
+		}
+	}
+	gasOracle, err := tools.NewGasOracle(servConfig.BSCConfig.GasPrice, client, servConfig.BSCConfig.URL)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := gasOracle.SuggestGasPrice(context.Background())
+	if err != nil {
+		return err
+	}
+	fee := new(big.Int).Mul(gasPrice, big.NewInt(tools.TransferGasLimit))
+	plan := tools.PlanRebalance(addrs, balances, fee, new(big.Int).SetUint64(ctx.Uint64(GetFlagName(MinAmountFlag))))
+	if len(plan) == 0 {
+		fmt.Println("nothing to rebalance")
+		return nil
+	}
+	for _, v := range plan {
+		fmt.Printf("%s -> %s: %s wei\n", v.From.Hex(), v.To.Hex(), v.Amount.String())
+	}
+	if ctx.Bool(GetFlagName(DryRunFlag)) {
+		return nil
+	}
+
+	// the nonces come from the DB of the relayer, which is locked while it runs
+	boltDB, err := openDB(servConfig)
+	if err != nil {
+		return err
+	}
+	defer boltDB.Close()
+	nonceManager := tools.NewNonceManager(client, boltDB)
+	unlocked := make(map[common.Address]accounts.Account)
+	for _, v := range plan {
+		acc, ok := unlocked[v.From]
+		if !ok {
+			if acc, err = unlockAccount(servConfig, ks, v.From.Hex()); err != nil {
+				return err
+			}
+			unlocked[v.From] = acc
+		}
+		nonce, err := nonceManager.GetAddressNonce(v.From)
+		if err != nil {
+			return err
+		}
+		tx, err := ks.SignTransaction(types.NewTransaction(nonce, v.To, v.Amount, tools.TransferGasLimit, gasPrice, nil), acc)
+		if err == nil {
+			err = client.SendTransaction(context.Background(), tx)
+		}
+		if err != nil {
+			nonceManager.ReturnNonce(v.From, nonce)
+			return fmt.Errorf("failed to send %s -> %s: %v", v.From.Hex(), v.To.Hex(), err)
+		}
+		fmt.Printf("sent %s\n", tx.Hash().Hex())
+	}
+	return nil
+}
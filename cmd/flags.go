@@ -18,6 +18,7 @@ package cmd
 
 import (
 	"strings"
+	"time"
 
 	"github.com/polynetwork/bsc-relayer/config"
 	"github.com/urfave/cli"
@@ -78,11 +79,52 @@ var (
 		Value: "./Log/",
 	}
 
-	//EncryptFlag = cli.StringFlag{
-	//	Name:  "encrypt",
-	//	Usage: "encrypt string `pwd`",
-	//	Value: "",
-	//}
+	LogMaxSizeFlag = cli.Int64Flag{
+		Name:  "logmaxsize",
+		Usage: "Start a new log file when the current one reaches `<MB>`",
+		Value: 20,
+	}
+
+	LogRotateFlag = cli.DurationFlag{
+		Name:  "logrotate",
+		Usage: "Start a new log file after `<duration>` like 24h, 0 to rotate by size only",
+		Value: 24 * time.Hour,
+	}
+
+	LogMaxFilesFlag = cli.IntFlag{
+		Name:  "logmaxfiles",
+		Usage: "Keep `<number>` rotated log files and remove the older ones, 0 to keep all",
+		Value: 30,
+	}
+
+	LogCompressFlag = cli.BoolFlag{
+		Name:  "logcompress",
+		Usage: "Compress rotated log files with gzip",
+	}
+
+	LogFormatFlag = cli.StringFlag{
+		Name:  "logformat",
+		Usage: "Write logs as `<format>`, text or json",
+		Value: "text",
+	}
+
+	LogModuleLevelsFlag = cli.StringFlag{
+		Name:  "loglevels",
+		Usage: "Set the level of modules like `EthSender=debug,tools=3`, a module is a type like BSCManager or a package like tools",
+		Value: "",
+	}
+
+	FeeMockFlag = cli.StringFlag{
+		Name:  "fee-mock",
+		Usage: "Answer fee checks from the rules `<file>` instead of the bridge fee service",
+		Value: "",
+	}
+
+	MasterKeyFileFlag = cli.StringFlag{
+		Name:  "master-key-file",
+		Usage: "Decrypt the enc: secrets of the config with the key in `<file>`, RELAYER_MASTER_KEY_FILE or RELAYER_MASTER_KEY otherwise",
+		Value: "",
+	}
 )
 
 //GetFlagName deal with short flag, and return the flag name whether flag name have short name
@@ -0,0 +1,123 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/urfave/cli"
+)
+
+var (
+	JSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "print the records as JSON",
+	}
+
+	LookupCommand = cli.Command{
+		Name: "lookup",
+		Usage: "Show what happened to a cross chain tx, found by its bsc tx hash, poly tx hash or cross chain ID. " +
+			"A running relayer is asked through AdminAddr, otherwise the DB is read",
+		ArgsUsage: "<tx hash or cross chain ID>",
+		Flags:     []cli.Flag{JSONFlag},
+		Action:    lookup,
+	}
+)
+
+func lookup(ctx *cli.Context) error {
+	key := ctx.Args().First()
+	if key == "" {
+		return fmt.Errorf("tx hash or cross chain ID is missing")
+	}
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	records := make([]*db.Lifecycle, 0)
+	answered, err := callAdmin(servConfig, "/lookup?key="+url.QueryEscape(key), nil, &records)
+	if answered && err != nil {
+		return err
+	}
+	if !answered {
+		boltDB, dbErr := openDB(servConfig)
+		if dbErr != nil {
+			return fmt.Errorf("%v, and the relayer can not be asked: %v", dbErr, err)
+		}
+		defer boltDB.Close()
+		if records, err = boltDB.GetLifecycles(key); err != nil {
+			return err
+		}
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("nothing recorded for %s", key)
+	}
+	if ctx.Bool(GetFlagName(JSONFlag)) {
+		out, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	for _, v := range records {
+		printLifecycle(os.Stdout, v)
+	}
+	return nil
+}
+
+func printLifecycle(w io.Writer, record *db.Lifecycle) {
+	fmt.Fprintf(w, "%s source tx %s", record.Direction, record.SourceTx)
+	if record.CCID != "" {
+		fmt.Fprintf(w, " cross chain ID %s", record.CCID)
+	}
+	fmt.Fprintln(w)
+	for _, v := range record.Stages {
+		fields := []string{v.Time.Format(time.RFC3339), fmt.Sprintf("%-14s", v.Stage)}
+		if v.Status != "" {
+			fields = append(fields, v.Status)
+		}
+		if v.Height > 0 {
+			fields = append(fields, fmt.Sprintf("height %d", v.Height))
+		}
+		if v.BlockHash != "" {
+			fields = append(fields, "block "+v.BlockHash)
+		}
+		if v.TxHash != "" {
+			fields = append(fields, "tx "+v.TxHash)
+		}
+		if v.Sender != "" {
+			fields = append(fields, "sender "+v.Sender)
+		}
+		if v.Nonce != nil {
+			fields = append(fields, fmt.Sprintf("nonce %d", *v.Nonce))
+		}
+		if v.Count > 1 {
+			fields = append(fields, fmt.Sprintf("%d times", v.Count))
+		}
+		if v.Detail != "" {
+			fields = append(fields, "("+v.Detail+")")
+		}
+		fmt.Fprintf(w, "  %s\n", strings.Join(fields, " "))
+	}
+}
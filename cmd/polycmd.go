@@ -0,0 +1,264 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/tools"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	pcommon "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/common/password"
+	"github.com/urfave/cli"
+)
+
+var (
+	WaitFlag = cli.BoolFlag{
+		Name:  "wait",
+		Usage: "wait until the registration is approved",
+	}
+
+	PolyWalletCommand = cli.Command{
+		Name:  "poly-wallet",
+		Usage: "Manage the poly wallet WalletFile",
+		Subcommands: []cli.Command{
+			{
+				Name:   "create",
+				Usage:  "Create the wallet with a new default account",
+				Action: createPolyWallet,
+			},
+			{
+				Name:   "add",
+				Usage:  "Add a new account for ProofAccounts to the wallet",
+				Action: addPolyAccount,
+			},
+			{
+				Name:   "show",
+				Usage:  "Show the address of the default account and check WalletPwd",
+				Action: showPolyWallet,
+			},
+			{
+				Name:   "passwd",
+				Usage:  "Change the password of the default account",
+				Action: changePolyWalletPwd,
+			},
+		},
+	}
+
+	RegisterRelayerCommand = cli.Command{
+		Name:   "register-relayer",
+		Usage:  "Apply for the poly account and ProofAccounts to be relayers, or report their approval status",
+		Flags:  []cli.Flag{WaitFlag},
+		Action: registerRelayer,
+	}
+)
+
+func createPolyWallet(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	file := servConfig.PolyConfig.WalletFile
+	polySdk := sdk.NewPolySdk()
+	var wallet *sdk.Wallet
+	if pcommon.FileExisted(file) {
+		if wallet, err = polySdk.OpenWallet(file); err != nil {
+			return err
+		}
+		if acc, err := wallet.GetDefaultAccountData(); err == nil {
+			return fmt.Errorf("wallet %s already has the default account %s", file, acc.Address)
+		}
+	} else if wallet, err = polySdk.CreateWallet(file); err != nil {
+		return err
+	}
+	fmt.Println("Password of the new account.")
+	pwd, err := password.GetConfirmedPassword()
+	if err != nil {
+		return err
+	}
+	acc, err := wallet.NewDefaultSettingAccount(pwd)
+	if err != nil {
+		return err
+	}
+	if err = wallet.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("poly account %s ( %s ) saved to %s\n", acc.Address.ToBase58(), acc.Address.ToHexString(), file)
+	fmt.Println("set its password as WalletPwd and register it with register-relayer")
+	return nil
+}
+
+func addPolyAccount(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	file := servConfig.PolyConfig.WalletFile
+	wallet, err := sdk.NewPolySdk().OpenWallet(file)
+	if err != nil {
+		return err
+	}
+	if _, err = wallet.GetDefaultAccountData(); err != nil {
+		return fmt.Errorf("wallet %s has no default account, create it with poly-wallet create", file)
+	}
+	fmt.Println("Password of the new account.")
+	pwd, err := password.GetConfirmedPassword()
+	if err != nil {
+		return err
+	}
+	acc, err := wallet.NewDefaultSettingAccount(pwd)
+	if err != nil {
+		return err
+	}
+	if err = wallet.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("poly account %s ( %s ) saved to %s\n", acc.Address.ToBase58(), acc.Address.ToHexString(), file)
+	fmt.Println("add it to ProofAccounts, set its password in ProofAccountPwdSet if it is not WalletPwd, and register it with register-relayer")
+	return nil
+}
+
+func showPolyWallet(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	wallet, err := sdk.NewPolySdk().OpenWallet(servConfig.PolyConfig.WalletFile)
+	if err != nil {
+		return err
+	}
+	accData, err := wallet.GetDefaultAccountData()
+	if err != nil {
+		return fmt.Errorf("wallet %s: %v", servConfig.PolyConfig.WalletFile, err)
+	}
+	addr, err := pcommon.AddressFromBase58(accData.Address)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("poly account %s ( %s )\n", accData.Address, addr.ToHexString())
+	if servConfig.PolyConfig.WalletPwd == "" {
+		return nil
+	}
+	if _, err = wallet.GetDefaultAccount([]byte(servConfig.PolyConfig.WalletPwd)); err != nil {
+		return fmt.Errorf("WalletPwd is wrong: %v", err)
+	}
+	fmt.Println("WalletPwd is right")
+	return nil
+}
+
+func changePolyWalletPwd(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	file := servConfig.PolyConfig.WalletFile
+	wallet, err := sdk.NewPolySdk().OpenWallet(file)
+	if err != nil {
+		return err
+	}
+	accData, err := wallet.GetDefaultAccountData()
+	if err != nil {
+		return fmt.Errorf("wallet %s: %v", file, err)
+	}
+	old := []byte(servConfig.PolyConfig.WalletPwd)
+	if len(old) == 0 {
+		fmt.Print("Current password. ")
+		if old, err = password.GetPassword(); err != nil {
+			return err
+		}
+	}
+	fmt.Println("New password.")
+	pwd, err := password.GetConfirmedPassword()
+	if err != nil {
+		return err
+	}
+	if err = wallet.ChangeAccountPassword(accData.Address, old, pwd); err != nil {
+		return err
+	}
+	if err = wallet.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("password of %s changed, update WalletPwd\n", accData.Address)
+	return nil
+}
+
+func registerRelayer(ctx *cli.Context) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	polySdk, err := dialPoly(servConfig)
+	if err != nil {
+		return err
+	}
+	signer, addr, err := tools.NewPolySigner(servConfig.PolyConfig, polySdk)
+	if err != nil {
+		return err
+	}
+	proofSigners, err := tools.NewPolyProofSigners(servConfig.PolyConfig, polySdk)
+	if err != nil {
+		return err
+	}
+	addrs := []pcommon.Address{addr}
+	for _, v := range proofSigners {
+		addrs = append(addrs, v.Address)
+	}
+	pending, err := unapprovedRelayers(polySdk, addrs)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	applyID, err := tools.RegisterPolyRelayer(polySdk, signer, addr, pending)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("registration submitted with apply ID %d, waiting for the consensus nodes to approve it\n", applyID)
+	if !ctx.Bool(GetFlagName(WaitFlag)) {
+		return nil
+	}
+	for {
+		time.Sleep(10 * time.Second)
+		left, err := unapprovedRelayers(polySdk, pending)
+		if err != nil {
+			fmt.Printf("failed to get approval status: %v\n", err)
+			continue
+		}
+		if pending = left; len(pending) == 0 {
+			return nil
+		}
+	}
+}
+
+// unapprovedRelayers reports the approved addresses and returns the others
+func unapprovedRelayers(polySdk *sdk.PolySdk, addrs []pcommon.Address) ([]pcommon.Address, error) {
+	pending := make([]pcommon.Address, 0)
+	for _, addr := range addrs {
+		ok, err := tools.IsPolyRelayer(polySdk, addr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			fmt.Printf("%s is an approved relayer\n", addr.ToBase58())
+			continue
+		}
+		pending = append(pending, addr)
+	}
+	return pending, nil
+}
@@ -0,0 +1,70 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/poly/common/password"
+	"github.com/urfave/cli"
+)
+
+var (
+	PrintFlag = cli.BoolFlag{
+		Name:  "print",
+		Usage: "encrypt a typed in value and print it instead of rewriting the config",
+	}
+
+	EncryptCommand = cli.Command{
+		Name: "encrypt",
+		Usage: "Encrypt WalletPwd, KeyStorePwdSet, TreasuryPwd and AdminToken of the config in place with the master key, " +
+			"values already encrypted are kept",
+		Flags:  []cli.Flag{PrintFlag},
+		Action: encrypt,
+	}
+)
+
+func encrypt(ctx *cli.Context) error {
+	key, err := config.LoadMasterKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("no master key, use --%s, %s or %s", GetFlagName(MasterKeyFileFlag), config.MASTER_KEY_FILE_ENV, config.MASTER_KEY_ENV)
+	}
+	if ctx.Bool(GetFlagName(PrintFlag)) {
+		fmt.Print("Value to encrypt. ")
+		raw, err := password.GetConfirmedPassword()
+		if err != nil {
+			return err
+		}
+		secret, err := config.EncryptSecret(key, string(raw))
+		if err != nil {
+			return err
+		}
+		fmt.Println(secret)
+		return nil
+	}
+	configPath := ctx.GlobalString(GetFlagName(ConfigPathFlag))
+	count, err := config.EncryptConfigFile(key, configPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d secret(s) of %s encrypted\n", count, configPath)
+	return nil
+}
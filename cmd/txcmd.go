@@ -0,0 +1,155 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/tools"
+	"github.com/urfave/cli"
+)
+
+var (
+	AccountFlag = cli.StringFlag{
+		Name:  "account",
+		Usage: "relayer account `<address>`",
+	}
+
+	NonceFlag = cli.Uint64Flag{
+		Name:  "nonce",
+		Usage: "`<nonce>` of the transaction",
+	}
+
+	GasPriceFlag = cli.Uint64Flag{
+		Name:  "gasprice",
+		Usage: "gas price in wei, bumped from the pending transaction if not set",
+	}
+
+	SpeedUpCommand = cli.Command{
+		Name: "speedup",
+		Usage: "Replace a pending bsc transaction of a relayer account by the same one at a higher gas price. " +
+			"A running relayer does it through AdminAddr with AdminToken, otherwise the DB is used",
+		Flags:  []cli.Flag{AccountFlag, NonceFlag, GasPriceFlag},
+		Action: speedUp,
+	}
+
+	CancelCommand = cli.Command{
+		Name: "cancel",
+		Usage: "Replace a pending bsc transaction of a relayer account by a zero value self transfer. " +
+			"A running relayer does it through AdminAddr with AdminToken, otherwise the DB is used",
+		Flags:  []cli.Flag{AccountFlag, NonceFlag, GasPriceFlag},
+		Action: cancel,
+	}
+)
+
+func newTracker(ctx *cli.Context, servConfig *config.ServiceConfig) (*tools.TxTracker, func(), error) {
+	client, chainId, err := dialBSC(servConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	ks, err := newSigner(servConfig, chainId)
+	if err != nil {
+		return nil, nil, err
+	}
+	acc, err := unlockAccount(servConfig, ks, ctx.String(GetFlagName(AccountFlag)))
+	if err != nil {
+		return nil, nil, err
+	}
+	boltDB, err := openDB(servConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	gasOracle, err := tools.NewGasOracle(servConfig.BSCConfig.GasPrice, client, servConfig.BSCConfig.URL)
+	if err != nil {
+		boltDB.Close()
+		return nil, nil, err
+	}
+	nonceManager := tools.NewNonceManager(client, boltDB)
+	tracker, err := tools.NewTxTracker(acc, ks, client, nonceManager, gasOracle, boltDB, servConfig.BSCConfig.TxTracker)
+	if err != nil {
+		boltDB.Close()
+		return nil, nil, err
+	}
+	return tracker, boltDB.Close, nil
+}
+
+func gasPriceArg(ctx *cli.Context) *big.Int {
+	if !ctx.IsSet(GetFlagName(GasPriceFlag)) {
+		return nil
+	}
+	return new(big.Int).SetUint64(ctx.Uint64(GetFlagName(GasPriceFlag)))
+}
+
+func speedUp(ctx *cli.Context) error {
+	return replaceTx(ctx, "speedup", "replacement")
+}
+
+func cancel(ctx *cli.Context) error {
+	return replaceTx(ctx, "cancel", "cancellation")
+}
+
+// replaceTx asks the running relayer to replace the tx, or replaces it with a
+// tracker on the DB if the relayer can not be reached
+func replaceTx(ctx *cli.Context, action, name string) error {
+	servConfig, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	form := url.Values{
+		"action":  {action},
+		"account": {ctx.String(GetFlagName(AccountFlag))},
+		"nonce":   {strconv.FormatUint(ctx.Uint64(GetFlagName(NonceFlag)), 10)},
+	}
+	if gasPrice := gasPriceArg(ctx); gasPrice != nil {
+		form.Set("gasprice", gasPrice.String())
+	}
+	// manager.ReplacedTx
+	replaced := &struct {
+		Hash     string
+		GasPrice string
+	}{}
+	answered, err := callAdmin(servConfig, "/tx", form, replaced)
+	if answered {
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s sent by the relayer: %s, gas price %s\n", name, replaced.Hash, replaced.GasPrice)
+		return nil
+	}
+
+	tracker, closeDB, err := newTracker(ctx, servConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	var tx *types.Transaction
+	if action == "speedup" {
+		tx, err = tracker.SpeedUp(ctx.Uint64(GetFlagName(NonceFlag)), gasPriceArg(ctx))
+	} else {
+		tx, err = tracker.Cancel(ctx.Uint64(GetFlagName(NonceFlag)), gasPriceArg(ctx))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s sent: %s, gas price %s\n", name, tx.Hash().Hex(), tx.GasPrice().String())
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/tools"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/common/password"
+	"github.com/urfave/cli"
+)
+
+func loadConfig(ctx *cli.Context) (*config.ServiceConfig, error) {
+	configPath := ctx.GlobalString(GetFlagName(ConfigPathFlag))
+	servConfig := config.NewServiceConfig(configPath)
+	if servConfig == nil {
+		return nil, fmt.Errorf("failed to load config %s", configPath)
+	}
+	return servConfig, nil
+}
+
+func openDB(servConfig *config.ServiceConfig) (*db.BoltDB, error) {
+	if servConfig.BoltDbPath == "" {
+		return db.NewBoltDB("boltdb")
+	}
+	return db.NewBoltDB(servConfig.BoltDbPath)
+}
+
+// callAdmin asks the running relayer, which holds the lock of the DB, through
+// AdminAddr and decodes the JSON answer into out. A GET is sent if form is nil.
+// answered is false if AdminAddr is not set or the relayer can not be reached.
+func callAdmin(servConfig *config.ServiceConfig, path string, form url.Values, out interface{}) (answered bool, err error) {
+	if servConfig.AdminAddr == "" {
+		return false, fmt.Errorf("AdminAddr is not set")
+	}
+	method, body := http.MethodGet, io.Reader(nil)
+	if form != nil {
+		method, body = http.MethodPost, strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, "http://"+servConfig.AdminAddr+path, body)
+	if err != nil {
+		return false, err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if servConfig.AdminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+servConfig.AdminToken)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return true, fmt.Errorf("relayer answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return true, json.NewDecoder(resp.Body).Decode(out)
+}
+
+func dialBSC(servConfig *config.ServiceConfig) (*ethclient.Client, *big.Int, error) {
+	client, err := ethclient.Dial(servConfig.BSCConfig.RestURL[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot dial bsc node: %v", err)
+	}
+	chainId, err := client.ChainID(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get chain id: %v", err)
+	}
+	return client, chainId, nil
+}
+
+func remoteSigner(servConfig *config.ServiceConfig) bool {
+	return servConfig.BSCConfig.RemoteSigner != nil && servConfig.BSCConfig.RemoteSigner.URL != ""
+}
+
+// newSigner is the remote signer if configured, otherwise the keystore with
+// its accounts still locked
+func newSigner(servConfig *config.ServiceConfig, chainId *big.Int) (tools.Signer, error) {
+	if remoteSigner(servConfig) {
+		return tools.NewRemoteSigner(servConfig.BSCConfig.RemoteSigner, chainId)
+	}
+	return tools.NewEthKeyStore(servConfig.BSCConfig, chainId), nil
+}
+
+// unlockAccount finds the relayer account by address and unlocks it with the
+// password from config, or asks for it. Remote signer accounts need no unlock.
+func unlockAccount(servConfig *config.ServiceConfig, signer tools.Signer, address string) (accounts.Account, error) {
+	for _, acc := range signer.GetAccounts() {
+		if !strings.EqualFold(acc.Address.Hex(), address) {
+			continue
+		}
+		ks, ok := signer.(*tools.EthKeyStore)
+		if !ok {
+			return acc, nil
+		}
+		pwd, ok := servConfig.BSCConfig.KeyStorePwdSet[strings.ToLower(acc.Address.Hex())]
+		if !ok {
+			fmt.Printf("For address %s. ", acc.Address.Hex())
+			raw, err := password.GetPassword()
+			if err != nil {
+				return acc, err
+			}
+			pwd = string(raw)
+		}
+		return acc, ks.UnlockAccount(acc, pwd)
+	}
+	if remoteSigner(servConfig) {
+		return accounts.Account{}, fmt.Errorf("account %s not found in signer %s", address, servConfig.BSCConfig.RemoteSigner.URL)
+	}
+	return accounts.Account{}, fmt.Errorf("account %s not found under %s", address, servConfig.BSCConfig.KeyStorePath)
+}
+
+func dialPoly(servConfig *config.ServiceConfig) (*sdk.PolySdk, error) {
+	polySdk := sdk.NewPolySdk()
+	polySdk.NewRpcClient().SetAddress(servConfig.PolyConfig.RestURL)
+	hdr, err := polySdk.GetHeaderByHeight(0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial poly node: %v", err)
+	}
+	polySdk.SetChainId(hdr.ChainID)
+	return polySdk, nil
+}
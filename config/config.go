@@ -20,6 +20,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"os"
 	"strings"
 	"sync"
@@ -32,6 +34,20 @@ import (
 const (
 	BSC_MONITOR_INTERVAL = time.Second
 	ONT_MONITOR_INTERVAL = time.Second
+	GAS_PRICE_WAIT       = 5 * time.Second
+
+	NONCE_GAP_CHECK_INTERVAL = time.Minute
+	TX_TRACKER_INTERVAL      = 3 * time.Second
+	FEE_CHECK_INTERVAL       = 10 * time.Second
+	FEE_CHECK_BATCH          = 50
+	DEFAULT_FEE_EXPIRE_TIME  = 24 * time.Hour
+	BALANCE_CHECK_INTERVAL   = 5 * time.Minute
+	RELAYER_CHECK_INTERVAL   = 10 * time.Minute
+	TOP_UP_AUDIT_LOG         = "topup_audit.log"
+
+	FEE_POLICY_FAIL_CLOSED         = "fail-closed"
+	FEE_POLICY_FAIL_OPEN_ALLOWLIST = "fail-open-allowlist"
+	FEE_POLICY_FAIL_OPEN_TIMEOUT   = "fail-open-timeout"
 
 	BSC_USEFUL_BLOCK_NUM     = 3
 	ONT_USEFUL_BLOCK_NUM     = 1
@@ -39,6 +55,8 @@ const (
 	Version                  = "1.0"
 
 	DEFAULT_LOG_LEVEL = log.InfoLog
+
+	DEFAULT_GAS_LIMIT_MULTIPLIER = 1.1
 )
 
 //type ETH struct {
@@ -57,11 +75,40 @@ type ServiceConfig struct {
 	BoltDbPath       string
 	RoutineNum       int64
 	Free             bool
-	TargetContracts  []map[string]map[string][]uint64
+	TargetContracts  []map[string]*TargetContract
 	WhitelistMethods []string
+	MetricsAddr      string
+	AdminAddr        string // admin endpoints like /loglevel, a non loopback address needs AdminToken
+	AdminToken       string // bearer token the admin endpoints require, without it they are read only, may be encrypted
+	EventsConfig     *EventsConfig
 	whitelistMethods map[string]bool
 }
 
+// CheckAdmin refuses to expose the admin endpoints, which change the relayer,
+// on a non loopback address without AdminToken
+func (c *ServiceConfig) CheckAdmin() error {
+	if c.AdminToken != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(c.AdminAddr)
+	if err != nil {
+		return fmt.Errorf("invalid AdminAddr %s: %v", c.AdminAddr, err)
+	}
+	if IsLoopback(host) {
+		return nil
+	}
+	return fmt.Errorf("AdminAddr %s is not a loopback address, set AdminToken to serve the admin endpoints on it", c.AdminAddr)
+}
+
+// IsLoopback tells whether host, a name or an IP without port, is the local machine
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (c *ServiceConfig) IsWhitelistMethod(method string) bool {
 	c.Do(func() {
 		c.whitelistMethods = map[string]bool{}
@@ -73,8 +120,106 @@ func (c *ServiceConfig) IsWhitelistMethod(method string) bool {
 	return c.whitelistMethods[method]
 }
 
+// TargetContract limits the chains a lock proxy is relayed for, the gas limit
+// fields override the ones of BSCConfig.GasLimit for the contract
+type TargetContract struct {
+	Inbound            []uint64 `json:"inbound"`  // from which chain allowed
+	Outbound           []uint64 `json:"outbound"` // to which chain allowed
+	GasLimitMultiplier float64
+	MaxGasLimit        uint64
+	Free               bool // relayed without fee check
+}
+
+// GetTargetContract returns nil if the contract is not configured
+func (c *ServiceConfig) GetTargetContract(addr string) *TargetContract {
+	for _, v := range c.TargetContracts {
+		if target, ok := v[addr]; ok && target != nil {
+			return target
+		}
+	}
+	return nil
+}
+
+// IsFree tells whether transfers to the contract are relayed without fee check
+func (c *ServiceConfig) IsFree(toContract string) bool {
+	if c.Free {
+		return true
+	}
+	target := c.GetTargetContract(toContract)
+	return target != nil && target.Free
+}
+
+// GasLimitPolicy returns the multiplier and ceiling applied to the gas
+// estimated for a relay to the contract, a zero ceiling means no limit
+func (c *ServiceConfig) GasLimitPolicy(toContract string) (multiplier float64, max uint64) {
+	multiplier = DEFAULT_GAS_LIMIT_MULTIPLIER
+	if c.BSCConfig.GasLimit != nil {
+		if c.BSCConfig.GasLimit.Multiplier > 0 {
+			multiplier = c.BSCConfig.GasLimit.Multiplier
+		}
+		max = c.BSCConfig.GasLimit.MaxGasLimit
+	}
+	if target := c.GetTargetContract(toContract); target != nil {
+		if target.GasLimitMultiplier > 0 {
+			multiplier = target.GasLimitMultiplier
+		}
+		if target.MaxGasLimit > 0 {
+			max = target.MaxGasLimit
+		}
+	}
+	return
+}
+
+// EventsConfig are the outputs of the relay events, each event is delivered at
+// least once to the file and every webhook
+type EventsConfig struct {
+	File     string           // the events are appended to the file as JSON lines
+	Webhooks []*WebhookConfig // the events are posted one by one as JSON
+	SSEAddr  string           // the events are streamed on http://SSEAddr/events
+}
+
+type WebhookConfig struct {
+	URL     string
+	Headers map[string]string // like Authorization
+	Timeout uint64            // seconds, 10 by default
+}
+
 type BridgeConfig struct {
-	RestURL [][]string
+	RestURL          [][]string
+	NativeTokenPrice float64 // value of one BNB in the unit of the fee amounts reported by the bridge, 0 disables the profitability check
+	MinFeeRatio      float64 // relays whose fee covers this ratio of the BSC tx cost are sent, 1 by default
+	DeferFeeRatio    float64 // relays below MinFeeRatio but above this ratio wait for cheaper gas, the others are skipped
+	MaxDeferTime     uint64  // seconds a relay waits for cheaper gas in the fee queue before it is skipped
+	FeeExpireTime    uint64  // seconds an unpaid transfer stays in the fee queue, one day by default
+
+	// what to do while the fee service fails, see FEE_POLICY_*
+	FeeFailPolicy     string
+	FailOpenChains    []uint64 // source chains relayed unchecked by fail-open-allowlist
+	FailOpenContracts []string // target contracts relayed unchecked by fail-open-allowlist
+	FailOpenAfter     uint64   // seconds a transfer waits before fail-open-timeout relays it unchecked
+}
+
+// FailOpen tells whether a transfer may be relayed without a fee check while the
+// fee service fails, waited is the time the transfer has been waiting for it
+func (c *BridgeConfig) FailOpen(fromChainId uint64, toContract string, waited time.Duration) bool {
+	switch c.FeeFailPolicy {
+	case FEE_POLICY_FAIL_OPEN_ALLOWLIST:
+		for _, id := range c.FailOpenChains {
+			if id == fromChainId {
+				return true
+			}
+		}
+		for _, addr := range c.FailOpenContracts {
+			if strings.EqualFold(addr, toContract) {
+				return true
+			}
+		}
+		return false
+	case FEE_POLICY_FAIL_OPEN_TIMEOUT:
+		return waited >= time.Duration(c.FailOpenAfter)*time.Second
+	default:
+		return false
+	}
 }
 
 type PolyConfig struct {
@@ -82,6 +227,16 @@ type PolyConfig struct {
 	EntranceContractAddress string
 	WalletFile              string
 	WalletPwd               string
+	RemoteSigner            *RemoteSignerConfig // signs poly transactions instead of WalletFile
+	AllowNewWallet          bool                // create the wallet or its account if missing, the new account is not a relayer
+	ProofAccounts           []string            // accounts of WalletFile importing the proofs, the default account syncs headers
+	ProofAccountPwdSet      map[string]string   // passwords of ProofAccounts, WalletPwd if missing
+}
+
+// RemoteSignerConfig points to an external signer holding the keys
+type RemoteSignerConfig struct {
+	URL     string
+	Timeout uint64 // seconds to wait for a signature, 60 by default
 }
 
 type BSCConfig struct {
@@ -94,6 +249,69 @@ type BSCConfig struct {
 	KeyStorePwdSet      map[string]string
 	BlockConfig         uint64
 	HeadersPerBatch     int
+	GasPrice            *GasPriceConfig
+	TxTracker           *TxTrackerConfig
+	RelayConfirmations  uint64
+	GasLimit            *GasLimitConfig
+	SenderSelector      string   // balance, round-robin or least-pending
+	MinSenderBalance    *big.Int // wei, senders below it are not selected
+	BalanceCacheTime    uint64   // seconds a sender balance is cached
+	SenderMaxFailures   int      // consecutive failures before a sender is quarantined, 3 by default
+	SenderCooldown      uint64   // seconds a sender stays quarantined, 300 by default
+	SendQueueSize       int      // relays queued per sender before the poly scan waits, 64 by default
+	TopUp               *TopUpConfig
+	RemoteSigner        *RemoteSignerConfig // signs bsc transactions instead of KeyStorePath
+}
+
+// TopUpConfig sets the balance alerts of the senders and their top-ups from a
+// treasury account, the treasury keystore must not be KeyStorePath or the
+// treasury would relay like any sender
+type TopUpConfig struct {
+	CheckInterval    uint64   // seconds between two balance checks, 300 by default
+	AlertBalance     *big.Int // wei, a warning is logged for senders below it
+	TargetBalance    *big.Int // wei, senders below AlertBalance are topped up to it, 0 disables top-ups
+	DailyCap         *big.Int // wei, sent by the treasury per UTC day at most, 0 means no limit
+	TreasuryKeyStore string
+	TreasuryAccount  string
+	TreasuryPwd      string
+	AuditLog         string // file of the top-up audit entries, topup_audit.log by default
+}
+
+func (c *TopUpConfig) Enabled() bool {
+	return c != nil && c.TreasuryAccount != "" && Wei(c.TargetBalance).Cmp(Wei(c.AlertBalance)) > 0
+}
+
+// Wei is the amount of a config, 0 if not set
+func Wei(v *big.Int) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+	return v
+}
+
+type GasLimitConfig struct {
+	Multiplier  float64 // applied to the estimated gas limit, 1.1 by default
+	MaxGasLimit uint64  // relays estimated above it are refused, 0 means no limit
+}
+
+type GasPriceConfig struct {
+	Strategy             string  // node, percentile, fixed or eip1559
+	Multiplier           float64 // applied to the price from the strategy, 1.2 by default
+	FixedPrice           uint64  // wei, used by the fixed strategy
+	PercentileBlocks     uint64  // number of recent blocks sampled by the percentile strategy
+	Percentile           int     // percentile of the sampled tx prices
+	MaxFeePerGas         uint64  // wei, fee cap of the eip1559 strategy
+	MaxPriorityFeePerGas uint64  // wei, tip cap of the eip1559 strategy
+	FloorPrice           uint64  // wei, suggested prices are raised to it
+	CeilingPrice         uint64  // wei, suggested prices are lowered to it
+	MaxPrice             uint64  // wei, relays wait in the fee queue while the price is above it
+}
+
+type TxTrackerConfig struct {
+	SpeedupDelay uint64 // seconds an unmined tx waits before it is replaced with a higher price
+	BumpPercent  uint64 // price increase of each replacement, at least 10
+	MaxSpeedups  int    // replacements before giving up on a tx
+	CancelAfter  uint64 // seconds after which an unmined tx is cancelled by a self transfer, 0 never
 }
 
 func (c *BSCConfig) URL() string {
@@ -140,5 +358,15 @@ func NewServiceConfig(configFilePath string) *ServiceConfig {
 		servConfig.BSCConfig.KeyStorePwdSet[strings.ToLower(k)] = v
 	}
 
+	key, err := LoadMasterKey()
+	if err != nil {
+		log.Errorf("NewServiceConfig: failed, err: %s", err)
+		return nil
+	}
+	if err = servConfig.decryptSecrets(key); err != nil {
+		log.Errorf("NewServiceConfig: failed to decrypt secrets: %s", err)
+		return nil
+	}
+
 	return servConfig
 }
@@ -0,0 +1,235 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	SECRET_PREFIX       = "enc:"
+	MASTER_KEY_ENV      = "RELAYER_MASTER_KEY"
+	MASTER_KEY_FILE_ENV = "RELAYER_MASTER_KEY_FILE"
+)
+
+// MasterKeyFile is set by the --master-key-file flag and takes precedence over the env vars
+var MasterKeyFile string
+
+// LoadMasterKey reads the master key from MasterKeyFile, RELAYER_MASTER_KEY_FILE
+// or RELAYER_MASTER_KEY in this order, the AES key is its sha256. It returns
+// nil if none is set.
+func LoadMasterKey() ([]byte, error) {
+	var raw []byte
+	file := MasterKeyFile
+	if file == "" {
+		file = os.Getenv(MASTER_KEY_FILE_ENV)
+	}
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("LoadMasterKey - read %s error: %v", file, err)
+		}
+		raw = bytes.TrimSpace(data)
+	} else {
+		raw = []byte(os.Getenv(MASTER_KEY_ENV))
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	key := sha256.Sum256(raw)
+	return key[:], nil
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, SECRET_PREFIX)
+}
+
+// EncryptSecret seals value with AES-GCM, the result is enc: followed by the
+// base64 of the nonce and the sealed value
+func EncryptSecret(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return SECRET_PREFIX + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret opens a value from EncryptSecret, values without the enc: prefix are returned as they are
+func DecryptSecret(key []byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if key == nil {
+		return "", fmt.Errorf("no master key to decrypt secrets, set %s or %s", MASTER_KEY_ENV, MASTER_KEY_FILE_ENV)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SECRET_PREFIX))
+	if err != nil {
+		return "", fmt.Errorf("invalid secret: %v", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid secret: too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt secret, wrong master key?")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// secrets are the config values which may be encrypted
+func (c *ServiceConfig) secrets() []*string {
+	arr := []*string{&c.AdminToken}
+	if c.PolyConfig != nil {
+		arr = append(arr, &c.PolyConfig.WalletPwd)
+	}
+	if c.BSCConfig != nil && c.BSCConfig.TopUp != nil {
+		arr = append(arr, &c.BSCConfig.TopUp.TreasuryPwd)
+	}
+	return arr
+}
+
+func (c *ServiceConfig) decryptSecrets(key []byte) error {
+	for _, v := range c.secrets() {
+		plain, err := DecryptSecret(key, *v)
+		if err != nil {
+			return err
+		}
+		*v = plain
+	}
+	if c.PolyConfig != nil {
+		for addr, v := range c.PolyConfig.ProofAccountPwdSet {
+			plain, err := DecryptSecret(key, v)
+			if err != nil {
+				return fmt.Errorf("password of %s: %v", addr, err)
+			}
+			c.PolyConfig.ProofAccountPwdSet[addr] = plain
+		}
+	}
+	if c.BSCConfig == nil {
+		return nil
+	}
+	for addr, v := range c.BSCConfig.KeyStorePwdSet {
+		plain, err := DecryptSecret(key, v)
+		if err != nil {
+			return fmt.Errorf("password of %s: %v", addr, err)
+		}
+		c.BSCConfig.KeyStorePwdSet[addr] = plain
+	}
+	return nil
+}
+
+// secretPaths locate the secrets in the config file, * is any key
+var secretPaths = [][]string{
+	{"PolyConfig", "WalletPwd"},
+	{"PolyConfig", "ProofAccountPwdSet", "*"},
+	{"BSCConfig", "KeyStorePwdSet", "*"},
+	{"BSCConfig", "TopUp", "TreasuryPwd"},
+	{"AdminToken"},
+}
+
+// EncryptConfigFile encrypts the plain secrets of the config file in place and
+// returns how many were encrypted. Keys are matched case insensitively like
+// encoding/json does, the other values are kept as they are.
+func EncryptConfigFile(key []byte, file string) (int, error) {
+	data, err := ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var root map[string]interface{}
+	if err = decoder.Decode(&root); err != nil {
+		return 0, fmt.Errorf("EncryptConfigFile - parse %s error: %v", file, err)
+	}
+	count := 0
+	for _, path := range secretPaths {
+		if err = encryptPath(key, root, path, &count); err != nil {
+			return 0, err
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	out, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0, err
+	}
+	tmp := file + ".tmp"
+	if err = ioutil.WriteFile(tmp, append(out, '\n'), info.Mode()); err != nil {
+		return 0, err
+	}
+	return count, os.Rename(tmp, file)
+}
+
+func encryptPath(key []byte, node map[string]interface{}, path []string, count *int) error {
+	for k, v := range node {
+		if path[0] != "*" && !strings.EqualFold(k, path[0]) {
+			continue
+		}
+		if len(path) > 1 {
+			if child, ok := v.(map[string]interface{}); ok {
+				if err := encryptPath(key, child, path[1:], count); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		plain, ok := v.(string)
+		if !ok || plain == "" || IsEncrypted(plain) {
+			continue
+		}
+		secret, err := EncryptSecret(key, plain)
+		if err != nil {
+			return err
+		}
+		node[k] = secret
+		*count++
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	os.Setenv(MASTER_KEY_ENV, "master")
+	defer os.Unsetenv(MASTER_KEY_ENV)
+	key, err := LoadMasterKey()
+	assert.NoError(t, err)
+
+	secret, err := EncryptSecret(key, "pwd")
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(secret))
+	plain, err := DecryptSecret(key, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "pwd", plain)
+
+	plain, err = DecryptSecret(key, "pwd")
+	assert.NoError(t, err)
+	assert.Equal(t, "pwd", plain)
+
+	other, _ := EncryptSecret(key, "pwd")
+	assert.NotEqual(t, secret, other)
+
+	os.Setenv(MASTER_KEY_ENV, "another")
+	wrong, _ := LoadMasterKey()
+	_, err = DecryptSecret(wrong, secret)
+	assert.Error(t, err)
+	_, err = DecryptSecret(nil, secret)
+	assert.Error(t, err)
+}
+
+func TestEncryptConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`{
+  "PolyConfig": {"WalletFile": "./wallet.dat", "WalletPwd": "wallet", "ProofAccountPwdSet": {"AKx": "proof"}},
+  "BSCConfig": {
+    "KeyStorePwdSet": {"0xAbc": "pwd1", "0xdef": "pwd2"},
+    "TopUp": {"TargetBalance": 1000000000000000001, "TreasuryPwd": ""}
+  },
+  "RoutineNum": 64,
+  "AdminToken": "token"
+}`), 0600))
+
+	os.Setenv(MASTER_KEY_ENV, "master")
+	defer os.Unsetenv(MASTER_KEY_ENV)
+	key, _ := LoadMasterKey()
+	count, err := EncryptConfigFile(key, file)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	data, _ := ioutil.ReadFile(file)
+	assert.False(t, strings.Contains(string(data), "pwd1"))
+	assert.True(t, strings.Contains(string(data), "1000000000000000001"))
+
+	// encrypted values are kept
+	count, err = EncryptConfigFile(key, file)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	servConfig := NewServiceConfig(file)
+	assert.NotNil(t, servConfig)
+	assert.Equal(t, "wallet", servConfig.PolyConfig.WalletPwd)
+	assert.Equal(t, "proof", servConfig.PolyConfig.ProofAccountPwdSet["AKx"])
+	assert.Equal(t, "pwd1", servConfig.BSCConfig.KeyStorePwdSet["0xabc"])
+	assert.Equal(t, "pwd2", servConfig.BSCConfig.KeyStorePwdSet["0xdef"])
+	assert.Equal(t, "1000000000000000001", servConfig.BSCConfig.TopUp.TargetBalance.String())
+	assert.Equal(t, "token", servConfig.AdminToken)
+
+	os.Unsetenv(MASTER_KEY_ENV)
+	assert.Nil(t, NewServiceConfig(file))
+}
+
+func TestCheckAdmin(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:9091": true,
+		"[::1]:9091":     true,
+		"localhost:9091": true,
+		"0.0.0.0:9091":   false,
+		":9091":          false,
+		"10.0.0.5:9091":  false,
+		"127.0.0.1":      false,
+	} {
+		c := &ServiceConfig{AdminAddr: addr}
+		assert.Equal(t, ok, c.CheckAdmin() == nil, addr)
+	}
+	c := &ServiceConfig{AdminAddr: "0.0.0.0:9091", AdminToken: "token"}
+	assert.NoError(t, c.CheckAdmin())
+}
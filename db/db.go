@@ -17,12 +17,15 @@
 package db
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
@@ -30,9 +33,22 @@ import (
 const MAX_NUM = 1000
 
 var (
-	BKTCheck  = []byte("Check")
-	BKTRetry  = []byte("Retry")
-	BKTHeight = []byte("Height")
+	BKTCheck   = []byte("Check")
+	BKTRetry   = []byte("Retry")
+	BKTHeight  = []byte("Height")
+	BKTNonce   = []byte("Nonce")
+	BKTTracker = []byte("Tracker")
+	BKTSkip    = []byte("Skip")
+	BKTFee     = []byte("FeePending")
+	BKTTopUp   = []byte("TopUp")
+
+	BKTTopUpPending = []byte("Pending") // in BKTTopUp
+
+	BKTLifecycle      = []byte("Lifecycle")
+	BKTLifecycleIndex = []byte("LifecycleIndex")
+
+	BKTEventOutbox = []byte("EventOutbox")
+	BKTEventCursor = []byte("EventCursor")
 )
 
 type BoltDB struct {
@@ -46,7 +62,10 @@ func NewBoltDB(filePath string) (*BoltDB, error) {
 		filePath = path.Join(filePath, "bolt.bin")
 	}
 	w := new(BoltDB)
-	db, err := bolt.Open(filePath, 0644, &bolt.Options{InitialMmapSize: 500000})
+	db, err := bolt.Open(filePath, 0644, &bolt.Options{InitialMmapSize: 500000, Timeout: time.Second})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("%s is locked by another process, is the relayer running?", filePath)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -87,10 +106,123 @@ func NewBoltDB(filePath string) (*BoltDB, error) {
 		return nil, err
 	}
 
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTNonce)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTTracker)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTSkip)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTFee)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTTopUp)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTLifecycle)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTLifecycleIndex)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTEventOutbox)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTEventCursor)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
 	return w, nil
 }
 
-func (w *BoltDB) PutCheck(txHash string, v []byte) error {
+// checkBucket is the bucket of the signer inside Check, the entries written
+// before the signers were tracked are in Check itself under the empty signer
+func checkBucket(btx *bolt.Tx, signer string, create bool) (*bolt.Bucket, error) {
+	bucket := btx.Bucket(BKTCheck)
+	if signer == "" {
+		return bucket, nil
+	}
+	if create {
+		return bucket.CreateBucketIfNotExists([]byte(signer))
+	}
+	return bucket.Bucket([]byte(signer)), nil
+}
+
+// PutCheck records the poly tx submitted by signer, v is the retry it came from
+func (w *BoltDB) PutCheck(signer string, txHash string, v []byte) error {
 	w.rwlock.Lock()
 	defer w.rwlock.Unlock()
 
@@ -99,8 +231,11 @@ func (w *BoltDB) PutCheck(txHash string, v []byte) error {
 		return err
 	}
 	return w.db.Update(func(btx *bolt.Tx) error {
-		bucket := btx.Bucket(BKTCheck)
-		err := bucket.Put(k, v)
+		bucket, err := checkBucket(btx, signer, true)
+		if err != nil {
+			return err
+		}
+		err = bucket.Put(k, v)
 		if err != nil {
 			return err
 		}
@@ -109,7 +244,7 @@ func (w *BoltDB) PutCheck(txHash string, v []byte) error {
 	})
 }
 
-func (w *BoltDB) DeleteCheck(txHash string) error {
+func (w *BoltDB) DeleteCheck(signer string, txHash string) error {
 	w.rwlock.Lock()
 	defer w.rwlock.Unlock()
 
@@ -118,8 +253,11 @@ func (w *BoltDB) DeleteCheck(txHash string) error {
 		return err
 	}
 	return w.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(BKTCheck)
-		err := bucket.Delete(k)
+		bucket, err := checkBucket(tx, signer, false)
+		if err != nil || bucket == nil {
+			return err
+		}
+		err = bucket.Delete(k)
 		if err != nil {
 			return err
 		}
@@ -156,24 +294,45 @@ func (w *BoltDB) DeleteRetry(k []byte) error {
 	})
 }
 
-func (w *BoltDB) GetAllCheck() (map[string][]byte, error) {
+// GetAllCheck returns the poly txs to check by signer and tx hash
+func (w *BoltDB) GetAllCheck() (map[string]map[string][]byte, error) {
 	w.rwlock.Lock()
 	defer w.rwlock.Unlock()
 
-	checkMap := make(map[string][]byte)
-	err := w.db.Update(func(tx *bolt.Tx) error {
-		bw := tx.Bucket(BKTCheck)
-		bw.ForEach(func(k, v []byte) error {
+	checkMap := make(map[string]map[string][]byte)
+	count := 0
+	collect := func(signer string, bw *bolt.Bucket) error {
+		return bw.ForEach(func(k, v []byte) error {
+			if v == nil {
+				// bucket of a signer
+				return nil
+			}
 			_k := make([]byte, len(k))
 			_v := make([]byte, len(v))
 			copy(_k, k)
 			copy(_v, v)
-			checkMap[hex.EncodeToString(_k)] = _v
-			if len(checkMap) >= MAX_NUM {
+			if checkMap[signer] == nil {
+				checkMap[signer] = make(map[string][]byte)
+			}
+			checkMap[signer][hex.EncodeToString(_k)] = _v
+			count++
+			if count >= MAX_NUM {
 				return fmt.Errorf("max num")
 			}
 			return nil
 		})
+	}
+	err := w.db.Update(func(tx *bolt.Tx) error {
+		bw := tx.Bucket(BKTCheck)
+		if collect("", bw) != nil {
+			return nil
+		}
+		bw.ForEach(func(k, v []byte) error {
+			if v != nil {
+				return nil
+			}
+			return collect(string(k), bw.Bucket(k))
+		})
 		return nil
 	})
 	if err != nil {
@@ -237,6 +396,264 @@ func (w *BoltDB) GetPolyHeight() uint32 {
 	return h
 }
 
+// PutNonce saves the next nonce to issue, the confirmed nonce and the
+// nonces given back by failed sends of the account
+func (w *BoltDB) PutNonce(addr []byte, next, confirmed uint64, returned []uint64) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	raw := make([]byte, 16+8*len(returned))
+	binary.LittleEndian.PutUint64(raw, next)
+	binary.LittleEndian.PutUint64(raw[8:], confirmed)
+	for i, n := range returned {
+		binary.LittleEndian.PutUint64(raw[16+8*i:], n)
+	}
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(BKTNonce)
+		return bkt.Put(addr, raw)
+	})
+}
+
+func (w *BoltDB) GetNonce(addr []byte) (next, confirmed uint64, returned []uint64, err error) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	err = w.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(BKTNonce)
+		raw := bkt.Get(addr)
+		if len(raw) == 0 {
+			return nil
+		}
+		if len(raw) < 16 || len(raw)%8 != 0 {
+			return fmt.Errorf("invalid nonce record length %d", len(raw))
+		}
+		next = binary.LittleEndian.Uint64(raw)
+		confirmed = binary.LittleEndian.Uint64(raw[8:])
+		for i := 16; i < len(raw); i += 8 {
+			returned = append(returned, binary.LittleEndian.Uint64(raw[i:]))
+		}
+		return nil
+	})
+	return
+}
+
+func trackerKey(addr []byte, nonce uint64) []byte {
+	k := make([]byte, len(addr)+8)
+	copy(k, addr)
+	binary.BigEndian.PutUint64(k[len(addr):], nonce)
+	return k
+}
+
+// PutTrackedTx saves an outstanding transaction of the account
+func (w *BoltDB) PutTrackedTx(addr []byte, nonce uint64, v []byte) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(BKTTracker)
+		return bkt.Put(trackerKey(addr, nonce), v)
+	})
+}
+
+func (w *BoltDB) DeleteTrackedTx(addr []byte, nonce uint64) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(BKTTracker)
+		return bkt.Delete(trackerKey(addr, nonce))
+	})
+}
+
+func (w *BoltDB) GetTrackedTx(addr []byte, nonce uint64) []byte {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	var v []byte
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(BKTTracker).Get(trackerKey(addr, nonce))
+		if len(raw) > 0 {
+			v = make([]byte, len(raw))
+			copy(v, raw)
+		}
+		return nil
+	})
+	return v
+}
+
+// GetAllTrackedTx returns the outstanding transactions of the account by nonce
+func (w *BoltDB) GetAllTrackedTx(addr []byte) (map[uint64][]byte, error) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	txs := make(map[uint64][]byte)
+	err := w.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(BKTTracker).Cursor()
+		for k, v := c.Seek(addr); k != nil && bytes.HasPrefix(k, addr); k, v = c.Next() {
+			if len(k) != len(addr)+8 {
+				continue
+			}
+			_v := make([]byte, len(v))
+			copy(_v, v)
+			txs[binary.BigEndian.Uint64(k[len(addr):])] = _v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// PutSkip records why a relay was given up, keyed by poly tx hash or header label
+func (w *BoltDB) PutSkip(key string, reason string) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	v := make([]byte, 8+len(reason))
+	binary.LittleEndian.PutUint64(v, uint64(time.Now().Unix()))
+	copy(v[8:], reason)
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTSkip).Put([]byte(key), v)
+	})
+}
+
+// GetSkip returns the reason and the time a relay was skipped, the reason is empty if never skipped
+func (w *BoltDB) GetSkip(key string) (reason string, at time.Time) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(BKTSkip).Get([]byte(key))
+		if len(raw) < 8 {
+			return nil
+		}
+		at = time.Unix(int64(binary.LittleEndian.Uint64(raw)), 0)
+		reason = string(raw[8:])
+		return nil
+	})
+	return
+}
+
+// PutFeePending queues a transfer waiting for its fee, keyed by poly tx hash
+func (w *BoltDB) PutFeePending(polyTxHash string, v []byte) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTFee).Put([]byte(polyTxHash), v)
+	})
+}
+
+func (w *BoltDB) DeleteFeePending(polyTxHash string) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTFee).Delete([]byte(polyTxHash))
+	})
+}
+
+func (w *BoltDB) GetAllFeePending() (map[string][]byte, error) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	pending := make(map[string][]byte)
+	err := w.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTFee).ForEach(func(k, v []byte) error {
+			_v := make([]byte, len(v))
+			copy(_v, v)
+			pending[string(k)] = _v
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// AddTopUp adds amount to the total sent by the treasury on day and returns the new total
+func (w *BoltDB) AddTopUp(day string, amount *big.Int) (*big.Int, error) {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	total := new(big.Int)
+	err := w.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(BKTTopUp)
+		total.SetBytes(bucket.Get([]byte(day)))
+		total.Add(total, amount)
+		return bucket.Put([]byte(day), total.Bytes())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return total, nil
+}
+
+// GetTopUp returns the total sent by the treasury on day
+func (w *BoltDB) GetTopUp(day string) *big.Int {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	total := new(big.Int)
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		total.SetBytes(tx.Bucket(BKTTopUp).Get([]byte(day)))
+		return nil
+	})
+	return total
+}
+
+// PutPendingTopUp records the top-up tx of the sender until it is mined
+func (w *BoltDB) PutPendingTopUp(sender []byte, txHash []byte) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(BKTTopUp).CreateBucketIfNotExists(BKTTopUpPending)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(sender, txHash)
+	})
+}
+
+func (w *BoltDB) DeletePendingTopUp(sender []byte) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(BKTTopUp).Bucket(BKTTopUpPending)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete(sender)
+	})
+}
+
+// GetPendingTopUps returns the top-up txs not mined yet by sender
+func (w *BoltDB) GetPendingTopUps() (map[string][]byte, error) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	pending := make(map[string][]byte)
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(BKTTopUp).Bucket(BKTTopUpPending)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			pending[string(k)] = append([]byte{}, v...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
 func (w *BoltDB) Close() {
 	w.rwlock.Lock()
 	w.db.Close()
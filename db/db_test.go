@@ -0,0 +1,56 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package db
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topup")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	w, err := NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Close()
+
+	total, err := w.AddTopUp("2020-10-01", big.NewInt(5))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total.Int64())
+
+	pending, err := w.GetPendingTopUps()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.NoError(t, w.PutPendingTopUp([]byte{0x01}, []byte{0xaa}))
+	assert.NoError(t, w.PutPendingTopUp([]byte{0x02}, []byte{0xbb}))
+	assert.NoError(t, w.DeletePendingTopUp([]byte{0x01}))
+	pending, err = w.GetPendingTopUps()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"\x02": {0xbb}}, pending)
+
+	// the daily totals are next to the pending top-ups
+	assert.Equal(t, int64(5), w.GetTopUp("2020-10-01").Int64())
+}
@@ -0,0 +1,138 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package db
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+)
+
+// PutEvent appends the event to the outbox and returns its ID, the IDs are increasing
+func (w *BoltDB) PutEvent(v []byte) (id uint64, err error) {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	err = w.db.Update(func(btx *bolt.Tx) error {
+		id, err = putEvent(btx, v)
+		return err
+	})
+	return
+}
+
+func putEvent(btx *bolt.Tx, v []byte) (uint64, error) {
+	bucket := btx.Bucket(BKTEventOutbox)
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	return id, bucket.Put(eventKey(id), v)
+}
+
+// GetEvents returns at most limit events of the outbox after the ID
+func (w *BoltDB) GetEvents(after uint64, limit int) (ids []uint64, events [][]byte, err error) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	err = w.db.View(func(btx *bolt.Tx) error {
+		c := btx.Bucket(BKTEventOutbox).Cursor()
+		for k, v := c.Seek(eventKey(after + 1)); k != nil && len(ids) < limit; k, v = c.Next() {
+			ids = append(ids, binary.BigEndian.Uint64(k))
+			events = append(events, append([]byte{}, v...))
+		}
+		return nil
+	})
+	return
+}
+
+// LastEventID is the ID of the last event put, 0 if none
+func (w *BoltDB) LastEventID() (id uint64) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	_ = w.db.View(func(btx *bolt.Tx) error {
+		id = btx.Bucket(BKTEventOutbox).Sequence()
+		return nil
+	})
+	return
+}
+
+// FirstEventID is the ID of the first event kept in the outbox, 0 if none
+func (w *BoltDB) FirstEventID() (id uint64) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	_ = w.db.View(func(btx *bolt.Tx) error {
+		if k, _ := btx.Bucket(BKTEventOutbox).Cursor().First(); k != nil {
+			id = binary.BigEndian.Uint64(k)
+		}
+		return nil
+	})
+	return
+}
+
+// DeleteEvents removes the events of the outbox before the ID
+func (w *BoltDB) DeleteEvents(before uint64) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(btx *bolt.Tx) error {
+		bucket := btx.Bucket(BKTEventOutbox)
+		keys := make([][]byte, 0)
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) < before; k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PutEventCursor saves the ID of the last event delivered to the sink
+func (w *BoltDB) PutEventCursor(sink string, id uint64) error {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	return w.db.Update(func(btx *bolt.Tx) error {
+		return btx.Bucket(BKTEventCursor).Put([]byte(sink), eventKey(id))
+	})
+}
+
+// GetEventCursor returns the ID of the last event delivered to the sink and
+// false for a new sink
+func (w *BoltDB) GetEventCursor(sink string) (id uint64, ok bool) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	_ = w.db.View(func(btx *bolt.Tx) error {
+		if v := btx.Bucket(BKTEventCursor).Get([]byte(sink)); len(v) == 8 {
+			id, ok = binary.BigEndian.Uint64(v), true
+		}
+		return nil
+	})
+	return
+}
+
+func eventKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
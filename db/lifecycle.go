@@ -0,0 +1,171 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// stages kept per cross chain tx, the first one and the last ones
+const MAX_LIFECYCLE_STAGES = 100
+
+// LifecycleStage is one step of a cross chain tx
+type LifecycleStage struct {
+	Stage     string
+	Time      time.Time
+	Height    uint64  `json:",omitempty"`
+	BlockHash string  `json:",omitempty"`
+	TxHash    string  `json:",omitempty"`
+	Sender    string  `json:",omitempty"`
+	Nonce     *uint64 `json:",omitempty"`
+	Status    string  `json:",omitempty"`
+	Detail    string  `json:",omitempty"`
+	Count     int     `json:",omitempty"` // times the stage repeated, like a failing import
+}
+
+// Lifecycle is what happened to a cross chain tx, found by its source tx,
+// its cross chain ID and the txs of its stages
+type Lifecycle struct {
+	ID        string
+	Direction string
+	CCID      string `json:",omitempty"`
+	SourceTx  string
+	Stages    []*LifecycleStage
+}
+
+// NormalizeKey is the hex of a tx hash or cross chain ID without 0x in lower case
+func NormalizeKey(key string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(key, "0x"), "0X"))
+}
+
+func (this *LifecycleStage) repeats(stage *LifecycleStage) bool {
+	return this.Stage == stage.Stage && this.Status == stage.Status && this.Detail == stage.Detail &&
+		this.TxHash == stage.TxHash && this.Sender == stage.Sender
+}
+
+// AddLifecycleStage appends the stage to the record with the ID of record, which
+// is created if missing. The record is indexed by its source tx, its cross chain
+// ID, the tx of the stage and the keys. The saved record is returned.
+func (w *BoltDB) AddLifecycleStage(record *Lifecycle, stage *LifecycleStage, keys ...string) (*Lifecycle, error) {
+	return w.AddLifecycleStageWithEvent(record, stage, nil, keys...)
+}
+
+// AddLifecycleStageWithEvent is AddLifecycleStage which also puts the event
+// returned by event for the saved record in the outbox, in the same transaction
+// so that no stage loses its event. No event is put if event returns nil.
+func (w *BoltDB) AddLifecycleStageWithEvent(record *Lifecycle, stage *LifecycleStage, event func(saved *Lifecycle) ([]byte, error), keys ...string) (*Lifecycle, error) {
+	w.rwlock.Lock()
+	defer w.rwlock.Unlock()
+
+	if stage.Time.IsZero() {
+		stage.Time = time.Now().UTC()
+	}
+	saved := new(Lifecycle)
+	err := w.db.Update(func(btx *bolt.Tx) error {
+		bucket := btx.Bucket(BKTLifecycle)
+		if raw := bucket.Get([]byte(record.ID)); raw != nil {
+			if err := json.Unmarshal(raw, saved); err != nil {
+				return err
+			}
+		} else {
+			*saved = Lifecycle{ID: record.ID, Direction: record.Direction, SourceTx: NormalizeKey(record.SourceTx)}
+		}
+		if saved.CCID == "" {
+			saved.CCID = NormalizeKey(record.CCID)
+		}
+		if n := len(saved.Stages); n > 0 && saved.Stages[n-1].repeats(stage) {
+			last := saved.Stages[n-1]
+			stage.Count = last.Count + 1
+			if stage.Count == 1 {
+				stage.Count = 2
+			}
+			saved.Stages[n-1] = stage
+		} else {
+			saved.Stages = append(saved.Stages, stage)
+		}
+		if n := len(saved.Stages); n > MAX_LIFECYCLE_STAGES {
+			saved.Stages = append(saved.Stages[:1], saved.Stages[n-MAX_LIFECYCLE_STAGES+1:]...)
+		}
+		raw, err := json.Marshal(saved)
+		if err != nil {
+			return err
+		}
+		if err = bucket.Put([]byte(record.ID), raw); err != nil {
+			return err
+		}
+
+		index := btx.Bucket(BKTLifecycleIndex)
+		for _, k := range append(keys, saved.SourceTx, saved.CCID, stage.TxHash) {
+			if k = NormalizeKey(k); k == "" {
+				continue
+			}
+			if err = index.Put([]byte(k+"/"+record.ID), []byte{0x00}); err != nil {
+				return err
+			}
+		}
+		if event == nil {
+			return nil
+		}
+		evt, err := event(saved)
+		if err != nil || evt == nil {
+			return err
+		}
+		_, err = putEvent(btx, evt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// GetLifecycles finds the records by a tx hash or cross chain ID
+func (w *BoltDB) GetLifecycles(key string) ([]*Lifecycle, error) {
+	w.rwlock.RLock()
+	defer w.rwlock.RUnlock()
+
+	records := make([]*Lifecycle, 0)
+	err := w.db.View(func(btx *bolt.Tx) error {
+		bucket := btx.Bucket(BKTLifecycle)
+		prefix := []byte(NormalizeKey(key) + "/")
+		c := btx.Bucket(BKTLifecycleIndex).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			raw := bucket.Get(k[len(prefix):])
+			if raw == nil {
+				continue
+			}
+			record := new(Lifecycle)
+			if err := json.Unmarshal(raw, record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			if len(records) >= MAX_NUM {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
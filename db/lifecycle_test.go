@@ -0,0 +1,91 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	w, err := NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Close()
+
+	record := &Lifecycle{ID: "bsc_to_poly/abcd/01", Direction: "bsc_to_poly", CCID: "01", SourceTx: "0xABCD"}
+	_, err = w.AddLifecycleStage(record, &LifecycleStage{Stage: "event_seen", Height: 10, BlockHash: "ff"})
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = w.AddLifecycleStage(record, &LifecycleStage{Stage: "poly_import", Status: "failed", Detail: "busy"})
+		assert.NoError(t, err)
+	}
+	_, err = w.AddLifecycleStage(record, &LifecycleStage{Stage: "poly_import", Status: "success", TxHash: "0x1234"})
+	assert.NoError(t, err)
+
+	// found by the source tx, the cross chain ID and the tx of a stage
+	for _, key := range []string{"abcd", "0xabcd", "01", "1234"} {
+		records, err := w.GetLifecycles(key)
+		assert.NoError(t, err)
+		if !assert.Len(t, records, 1, key) {
+			return
+		}
+		assert.Equal(t, "abcd", records[0].SourceTx)
+		assert.Equal(t, "01", records[0].CCID)
+	}
+	records, err := w.GetLifecycles("abc")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	// the failed imports are merged
+	records, err = w.GetLifecycles("01")
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 1) {
+		return
+	}
+	stages := records[0].Stages
+	if !assert.Len(t, stages, 3) {
+		return
+	}
+	assert.Equal(t, uint64(10), stages[0].Height)
+	assert.Equal(t, 3, stages[1].Count)
+	assert.Equal(t, "success", stages[2].Status)
+
+	// the first stage is kept when the stages are capped
+	for i := 0; i < MAX_LIFECYCLE_STAGES; i++ {
+		status := "a"
+		if i%2 == 0 {
+			status = "b"
+		}
+		_, err = w.AddLifecycleStage(record, &LifecycleStage{Stage: "poly_import", Status: status})
+		assert.NoError(t, err)
+	}
+	records, err = w.GetLifecycles("abcd")
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 1) {
+		return
+	}
+	assert.Len(t, records[0].Stages, MAX_LIFECYCLE_STAGES)
+	assert.Equal(t, "event_seen", records[0].Stages[0].Stage)
+}
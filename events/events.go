@@ -0,0 +1,282 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package events publishes the lifecycle of the relayed txs to downstream
+// services. An event is put in the outbox of the DB before it is delivered, the
+// event of a lookup stage in the transaction which saves the stage, so every
+// sink takes the events in order and at least once.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/log"
+	"github.com/polynetwork/bsc-relayer/metrics"
+)
+
+// types of the events
+const (
+	Seen            = "seen"
+	Submitted       = "submitted"
+	Confirmed       = "confirmed"
+	Failed          = "failed"
+	SkippedUnpaid   = "skipped_unpaid"
+	SkippedFiltered = "skipped_filtered"
+)
+
+const (
+	EVENT_BATCH        = 100
+	EVENT_KEEP         = 10000 // events kept after delivery, for SSE clients to catch up
+	MAX_RETRY_INTERVAL = time.Minute
+)
+
+var (
+	eventsPending = metrics.NewGaugeVec("relayer_events_pending",
+		"Events waiting to be delivered to the sink", "sink")
+	eventFailures = metrics.NewCounterVec("relayer_event_delivery_failures_total",
+		"Failed deliveries of events to the sink", "sink")
+)
+
+// Event is a stage reached by a cross chain tx
+type Event struct {
+	ID        uint64 `json:",omitempty"` // position in the outbox, increasing
+	Type      string
+	Stage     string // stage of the lookup command, like bsc_relay
+	Direction string // bsc_to_poly or poly_to_bsc
+	Time      time.Time
+	SourceTx  string
+	CCID      string  `json:",omitempty"`
+	TxHash    string  `json:",omitempty"`
+	Height    uint64  `json:",omitempty"`
+	Sender    string  `json:",omitempty"`
+	Nonce     *uint64 `json:",omitempty"`
+	Detail    string  `json:",omitempty"`
+}
+
+// EventSink is an output of the events, an event is sent again until Send
+// succeeds
+type EventSink interface {
+	Name() string // unique and stable, it keeps the position of the sink in the DB
+	Send(evt *Event) error
+}
+
+// Publisher puts the events in the outbox and delivers them to the sinks
+type Publisher struct {
+	db    *db.BoltDB
+	sinks []EventSink
+
+	lock   sync.Mutex
+	update chan struct{} // closed when an event is put
+}
+
+var std *Publisher
+
+// SetDefault makes Publish put the events to p, it is called before the
+// managers start
+func SetDefault(p *Publisher) {
+	std = p
+}
+
+// Publish puts the event to the default publisher, if any, a failure is only
+// logged
+func Publish(evt *Event) {
+	if std == nil {
+		return
+	}
+	if err := std.Publish(evt); err != nil {
+		log.Errorf("Publish - failed to publish %s event of %s: %v", evt.Type, evt.SourceTx, err)
+	}
+}
+
+// Enabled tells whether a default publisher is set
+func Enabled() bool {
+	return std != nil
+}
+
+// Encode prepares the event for the outbox like Publish does, for the callers
+// which put it in their own DB transaction. Notify is called once it is committed.
+func Encode(evt *Event) ([]byte, error) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now().UTC()
+	}
+	evt.ID = 0
+	return json.Marshal(evt)
+}
+
+// Notify wakes up the deliveries of the default publisher after an event was
+// put in the outbox by the caller
+func Notify() {
+	if std != nil {
+		std.notify()
+	}
+}
+
+// NewSinks creates the file and webhook sinks of the config
+func NewSinks(cfg *config.EventsConfig) ([]EventSink, error) {
+	sinks := make([]EventSink, 0)
+	if cfg.File != "" {
+		sink, err := NewFileSink(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	for _, v := range cfg.Webhooks {
+		sinks = append(sinks, NewWebhookSink(v))
+	}
+	return sinks, nil
+}
+
+// NewPublisher starts to deliver to the sinks, a new sink gets the events put
+// from now on
+func NewPublisher(boltDB *db.BoltDB, sinks ...EventSink) (*Publisher, error) {
+	this := &Publisher{db: boltDB, sinks: sinks, update: make(chan struct{})}
+	last := boltDB.LastEventID()
+	for _, sink := range sinks {
+		if _, ok := boltDB.GetEventCursor(sink.Name()); ok {
+			continue
+		}
+		if err := boltDB.PutEventCursor(sink.Name(), last); err != nil {
+			return nil, err
+		}
+	}
+	for _, sink := range sinks {
+		go this.deliver(sink)
+	}
+	go this.prune()
+	return this, nil
+}
+
+// Publish puts the event in the outbox, the ID and time of the event are set
+func (this *Publisher) Publish(evt *Event) error {
+	raw, err := Encode(evt)
+	if err != nil {
+		return err
+	}
+	if evt.ID, err = this.db.PutEvent(raw); err != nil {
+		return err
+	}
+	this.notify()
+	return nil
+}
+
+func (this *Publisher) notify() {
+	this.lock.Lock()
+	close(this.update)
+	this.update = make(chan struct{})
+	this.lock.Unlock()
+}
+
+// changed is closed when the next event is put
+func (this *Publisher) changed() <-chan struct{} {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.update
+}
+
+// events returns a batch of events of the outbox after the ID and the ID of the
+// last one read, a broken event is skipped
+func (this *Publisher) events(after uint64) ([]*Event, uint64, error) {
+	ids, raws, err := this.db.GetEvents(after, EVENT_BATCH)
+	if err != nil {
+		return nil, after, err
+	}
+	events := make([]*Event, 0, len(ids))
+	for i, raw := range raws {
+		evt := new(Event)
+		if err = json.Unmarshal(raw, evt); err != nil {
+			log.Errorf("events - drop event %d: %v", ids[i], err)
+			continue
+		}
+		evt.ID = ids[i]
+		events = append(events, evt)
+	}
+	if len(ids) > 0 {
+		after = ids[len(ids)-1]
+	}
+	return events, after, nil
+}
+
+func (this *Publisher) deliver(sink EventSink) {
+	name := sink.Name()
+	cursor, _ := this.db.GetEventCursor(name)
+	retry := time.Second
+	for {
+		update := this.changed()
+		eventsPending.Set(float64(this.db.LastEventID()-cursor), name)
+		events, last, err := this.events(cursor)
+		if err != nil {
+			log.Errorf("deliver - failed to read the events of %s: %v", name, err)
+			time.Sleep(retry)
+			continue
+		}
+		if last == cursor {
+			select {
+			case <-update:
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+		sent := last
+		for _, evt := range events {
+			if err = sink.Send(evt); err != nil {
+				sent = evt.ID - 1
+				break
+			}
+		}
+		if sent != cursor {
+			if err := this.db.PutEventCursor(name, sent); err != nil {
+				log.Errorf("deliver - failed to save the position of %s: %v", name, err)
+			}
+			cursor = sent
+		}
+		if err == nil {
+			retry = time.Second
+			continue
+		}
+		eventFailures.Inc(name)
+		log.Errorf("deliver - failed to send event %d to %s, retry in %s: %v", cursor+1, name, retry, err)
+		time.Sleep(retry)
+		if retry *= 2; retry > MAX_RETRY_INTERVAL {
+			retry = MAX_RETRY_INTERVAL
+		}
+	}
+}
+
+// prune removes the events delivered to all sinks but the last EVENT_KEEP ones
+func (this *Publisher) prune() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		before := this.db.LastEventID() + 1
+		for _, sink := range this.sinks {
+			if cursor, _ := this.db.GetEventCursor(sink.Name()); cursor+1 < before {
+				before = cursor + 1
+			}
+		}
+		if before <= EVENT_KEEP {
+			continue
+		}
+		if err := this.db.DeleteEvents(before - EVENT_KEEP); err != nil {
+			log.Errorf("prune - failed to delete events: %v", err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSink struct {
+	lock sync.Mutex
+	fail int
+	ids  []uint64
+}
+
+func (this *testSink) Name() string {
+	return "test"
+}
+
+func (this *testSink) Send(evt *Event) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.fail > 0 {
+		this.fail--
+		return errors.New("sink is down")
+	}
+	this.ids = append(this.ids, evt.ID)
+	return nil
+}
+
+func (this *testSink) sent() []uint64 {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return append([]uint64{}, this.ids...)
+}
+
+func newTestDB(t *testing.T) (*db.BoltDB, func()) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	boltDB, err := db.NewBoltDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return boltDB, func() {
+		boltDB.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	boltDB, clean := newTestDB(t)
+	defer clean()
+
+	// events put before the sink is known are not delivered to it
+	_, err := boltDB.PutEvent([]byte(`{"Type":"seen"}`))
+	assert.NoError(t, err)
+
+	failures := eventFailures.Get("test")
+	sink := &testSink{fail: 1}
+	p, err := NewPublisher(boltDB, sink)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Publish(&Event{Type: Seen, SourceTx: "aa"}))
+	assert.NoError(t, p.Publish(&Event{Type: Submitted, SourceTx: "aa"}))
+
+	// the failed event is sent again after a second
+	assert.Eventually(t, func() bool {
+		return len(sink.sent()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []uint64{2, 3}, sink.sent())
+	assert.Eventually(t, func() bool {
+		cursor, _ := boltDB.GetEventCursor("test")
+		return cursor == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, failures+1, eventFailures.Get("test"))
+
+	assert.NoError(t, boltDB.DeleteEvents(3))
+	ids, _, err := boltDB.GetEvents(0, EVENT_BATCH)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{3}, ids)
+}
+
+func TestFileAndWebhookSinks(t *testing.T) {
+	boltDB, clean := newTestDB(t)
+	defer clean()
+
+	file := filepath.Join(os.TempDir(), "relay_events_test.jsonl")
+	defer os.Remove(file)
+	var lock sync.Mutex
+	received := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		received = append(received, r.Header.Get("X-Relayer-Event-Id")+" "+r.Header.Get("X-Relayer-Event"))
+	}))
+	defer server.Close()
+
+	sinks, err := NewSinks(&config.EventsConfig{
+		File:     file,
+		Webhooks: []*config.WebhookConfig{{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sinks, 2)
+	p, err := NewPublisher(boltDB, sinks...)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Publish(&Event{Type: Confirmed, Direction: "poly_to_bsc", SourceTx: "bb", TxHash: "cc"}))
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"1 confirmed"}, received)
+	assert.Eventually(t, func() bool {
+		raw, _ := ioutil.ReadFile(file)
+		return strings.Count(string(raw), "\n") == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	raw, _ := ioutil.ReadFile(file)
+	evt := new(Event)
+	assert.NoError(t, json.Unmarshal(raw, evt))
+	assert.Equal(t, uint64(1), evt.ID)
+	assert.Equal(t, "cc", evt.TxHash)
+}
+
+func TestSSEHandler(t *testing.T) {
+	boltDB, clean := newTestDB(t)
+	defer clean()
+
+	p, err := NewPublisher(boltDB)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Publish(&Event{Type: Seen, SourceTx: "aa"}))
+	server := httptest.NewServer(p.SSEHandler())
+	defer server.Close()
+
+	// a client reconnecting after event 1 gets the events put since
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.NoError(t, p.Publish(&Event{Type: Submitted, SourceTx: "aa"}))
+
+	reader := bufio.NewReader(resp.Body)
+	lines := make([]string, 0)
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, "id: 2", lines[0])
+	assert.Equal(t, "event: submitted", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], `data: {"ID":2,"Type":"submitted"`), lines[2])
+}
+
+func TestSSEReset(t *testing.T) {
+	boltDB, clean := newTestDB(t)
+	defer clean()
+
+	p, err := NewPublisher(boltDB)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, p.Publish(&Event{Type: Seen, SourceTx: "aa"}))
+	}
+	assert.NoError(t, boltDB.DeleteEvents(3))
+	server := httptest.NewServer(p.SSEHandler())
+	defer server.Close()
+
+	// events 1 and 2 are pruned, the client is told before it gets event 3
+	resp, err := http.Get(server.URL + "?after=0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	lines := make([]string, 0)
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, "event: "+SSE_RESET, lines[0])
+	assert.Equal(t, `data: {"After":0,"First":3}`, lines[1])
+	assert.Equal(t, "id: 3", lines[2])
+}
@@ -0,0 +1,111 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+)
+
+// FileSink appends the events to a file as JSON lines
+type FileSink struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(file string) (*FileSink, error) {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("NewFileSink - open %s error: %v", file, err)
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (this *FileSink) Name() string {
+	return "file:" + this.file.Name()
+}
+
+// Send returns once the event is synced to the disk
+func (this *FileSink) Send(evt *Event) error {
+	raw, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if _, err = this.file.Write(append(raw, '\n')); err != nil {
+		return err
+	}
+	return this.file.Sync()
+}
+
+// WebhookSink posts the events one by one, a 2xx response acknowledges an
+// event. The receiver drops the repeated ones by the X-Relayer-Event-Id header.
+type WebhookSink struct {
+	config *config.WebhookConfig
+	client *http.Client
+}
+
+func NewWebhookSink(cfg *config.WebhookConfig) *WebhookSink {
+	timeout := 10 * time.Second
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	return &WebhookSink{config: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+func (this *WebhookSink) Name() string {
+	return "webhook:" + this.config.URL
+}
+
+func (this *WebhookSink) Send(evt *Event) error {
+	raw, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, this.config.URL, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	for k, v := range this.config.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Relayer-Event-Id", strconv.FormatUint(evt.ID, 10))
+	req.Header.Set("X-Relayer-Event", evt.Type)
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
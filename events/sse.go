@@ -0,0 +1,115 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const SSE_PING_INTERVAL = 15 * time.Second
+
+// SSE_RESET is sent to a client which asks for events already pruned from the
+// outbox, its data holds After, the ID asked for, and First, the first ID kept.
+// The events in between are lost for the client.
+const SSE_RESET = "reset"
+
+// SSEHandler streams the events as Server-Sent-Events. A client gets the events
+// put after it connects, or after the ID of the Last-Event-ID header or the
+// after parameter when it reconnects, as long as they are kept in the outbox.
+func (this *Publisher) SSEHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		cursor := this.db.LastEventID()
+		after := r.Header.Get("Last-Event-ID")
+		if after == "" {
+			after = r.URL.Query().Get("after")
+		}
+		if after != "" {
+			id, err := strconv.ParseUint(after, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid event ID "+after, http.StatusBadRequest)
+				return
+			}
+			cursor = id
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		if after != "" {
+			first := this.db.FirstEventID()
+			if first == 0 {
+				first = this.db.LastEventID() + 1
+			}
+			if cursor+1 < first {
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: {\"After\":%d,\"First\":%d}\n\n", SSE_RESET, cursor, first); err != nil {
+					return
+				}
+				cursor = first - 1
+			}
+		}
+		flusher.Flush()
+
+		ping := time.NewTicker(SSE_PING_INTERVAL)
+		defer ping.Stop()
+		for {
+			update := this.changed()
+			events, last, err := this.events(cursor)
+			if err != nil {
+				return
+			}
+			for _, evt := range events {
+				raw, err := json.Marshal(evt)
+				if err != nil {
+					return
+				}
+				if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.ID, evt.Type, raw); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if last != cursor {
+				cursor = last
+				continue
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-update:
+			case <-ping.C:
+				if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// Serve streams the events on http://addr/events, it blocks like http.ListenAndServe
+func (this *Publisher) Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/events", this.SSEHandler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,188 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package feemock is a stand-in for the checkfee API of the bridge fee service,
+// answering from a rules file. It is meant for tests and staging deployments.
+package feemock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"poly_bridge_sdk"
+)
+
+const (
+	StatePaid     = "paid"
+	StateNotPaid  = "notpaid"
+	StateNotCheck = "notcheck"
+)
+
+// Rule answers the checks matching ChainId and Hash, zero values match any
+type Rule struct {
+	ChainId     uint64
+	Hash        string
+	State       string // paid, notpaid or notcheck
+	Amount      string
+	MinProxyFee string
+	Fail        bool // answer with an internal error as if the service was down
+}
+
+func (this *Rule) payState() int {
+	switch this.State {
+	case StatePaid:
+		return poly_bridge_sdk.STATE_HASPAY
+	case StateNotCheck:
+		return poly_bridge_sdk.STATE_NOTCHECK
+	default:
+		return poly_bridge_sdk.STATE_NOTPAY
+	}
+}
+
+func (this *Rule) match(chainId uint64, hash string) bool {
+	return (this.ChainId == 0 || this.ChainId == chainId) &&
+		(this.Hash == "" || normalize(this.Hash) == normalize(hash))
+}
+
+// Rules are tried in order, Default answers the checks matching no rule
+type Rules struct {
+	Default *Rule
+	Rules   []*Rule
+}
+
+func LoadRules(file string) (*Rules, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("LoadRules - read %s error: %v", file, err)
+	}
+	rules := &Rules{}
+	if err = json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("LoadRules - parse %s error: %v", file, err)
+	}
+	for _, v := range append([]*Rule{rules.Default}, rules.Rules...) {
+		if v == nil {
+			continue
+		}
+		switch v.State {
+		case StatePaid, StateNotPaid, StateNotCheck:
+		default:
+			return nil, fmt.Errorf("LoadRules - unknown state %q in %s", v.State, file)
+		}
+	}
+	return rules, nil
+}
+
+func (this *Rules) find(chainId uint64, hash string) *Rule {
+	for _, v := range this.Rules {
+		if v.match(chainId, hash) {
+			return v
+		}
+	}
+	if this.Default != nil {
+		return this.Default
+	}
+	return &Rule{State: StateNotPaid}
+}
+
+// the items use the types of the sdk so their encoding is the one of the service
+type CheckFeesReq struct {
+	Checks []*poly_bridge_sdk.CheckFeeReq
+}
+
+type CheckFeesRsp struct {
+	TotalCount uint64
+	CheckFees  []*poly_bridge_sdk.CheckFeeRsp
+}
+
+// Server answers POST requests to any path ending with checkfee
+type Server struct {
+	lock     sync.RWMutex
+	rules    *Rules
+	listener net.Listener
+}
+
+func NewServer(rules *Rules) *Server {
+	return &Server{rules: rules}
+}
+
+// SetRules replaces the rules of a running server
+func (this *Server) SetRules(rules *Rules) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.rules = rules
+}
+
+func (this *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "checkfee") {
+		http.NotFound(w, r)
+		return
+	}
+	req := &CheckFeesReq{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	this.lock.RLock()
+	rules := this.rules
+	this.lock.RUnlock()
+	rsp := &CheckFeesRsp{CheckFees: make([]*poly_bridge_sdk.CheckFeeRsp, 0, len(req.Checks))}
+	for _, check := range req.Checks {
+		rule := rules.find(check.ChainId, check.Hash)
+		if rule.Fail {
+			http.Error(w, "fee service unavailable", http.StatusInternalServerError)
+			return
+		}
+		rsp.CheckFees = append(rsp.CheckFees, &poly_bridge_sdk.CheckFeeRsp{
+			ChainId:     check.ChainId,
+			Hash:        check.Hash,
+			PayState:    rule.payState(),
+			Amount:      rule.Amount,
+			MinProxyFee: rule.MinProxyFee,
+		})
+	}
+	rsp.TotalCount = uint64(len(rsp.CheckFees))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rsp)
+}
+
+// Start listens on addr, use 127.0.0.1:0 for a free port, and returns the URL to configure
+// as BridgeConfig.RestURL
+func (this *Server) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	this.listener = listener
+	go http.Serve(listener, this)
+	return "http://" + listener.Addr().String(), nil
+}
+
+func (this *Server) Stop() {
+	if this.listener != nil {
+		this.listener.Close()
+	}
+}
+
+func normalize(hash string) string {
+	return strings.ToLower(strings.TrimPrefix(hash, "0x"))
+}
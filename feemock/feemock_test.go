@@ -0,0 +1,86 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package feemock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"poly_bridge_sdk"
+)
+
+const testRules = `{
+  "Default": {"State": "notcheck"},
+  "Rules": [
+    {"Hash": "0xAB01", "State": "notpaid"},
+    {"ChainId": 2, "State": "paid", "Amount": "1.5"},
+    {"ChainId": 7, "Fail": true, "State": "paid"}
+  ]
+}`
+
+func checkFee(t *testing.T, url string, checks ...*poly_bridge_sdk.CheckFeeReq) (*CheckFeesRsp, int) {
+	data, _ := json.Marshal(&CheckFeesReq{Checks: checks})
+	resp, err := http.Post(url+"/v1/checkfee/", "application/json", bytes.NewReader(data))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode
+	}
+	rsp := &CheckFeesRsp{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(rsp))
+	return rsp, resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feemock")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "rules.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(testRules), 0600))
+
+	rules, err := LoadRules(file)
+	assert.NoError(t, err)
+	srv := NewServer(rules)
+	url, err := srv.Start("127.0.0.1:0")
+	assert.NoError(t, err)
+	defer srv.Stop()
+
+	rsp, code := checkFee(t, url,
+		&poly_bridge_sdk.CheckFeeReq{ChainId: 2, Hash: "ab01"},
+		&poly_bridge_sdk.CheckFeeReq{ChainId: 2, Hash: "cd02"},
+		&poly_bridge_sdk.CheckFeeReq{ChainId: 6, Hash: "cd02"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, uint64(3), rsp.TotalCount)
+	assert.Equal(t, poly_bridge_sdk.STATE_NOTPAY, rsp.CheckFees[0].PayState)
+	assert.Equal(t, poly_bridge_sdk.STATE_HASPAY, rsp.CheckFees[1].PayState)
+	assert.Equal(t, "1.5", rsp.CheckFees[1].Amount)
+	assert.Equal(t, poly_bridge_sdk.STATE_NOTCHECK, rsp.CheckFees[2].PayState)
+
+	_, code = checkFee(t, url, &poly_bridge_sdk.CheckFeeReq{ChainId: 7, Hash: "cd02"})
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`{"Default": {"State": "unknown"}}`), 0600))
+	_, err = LoadRules(file)
+	assert.Error(t, err)
+}
@@ -0,0 +1,276 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The poly network is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The poly network is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TextFormat = iota
+	JSONFormat
+)
+
+// field names of the relaying
+const (
+	FieldModule    = "module"
+	FieldDirection = "direction"
+	FieldBSCTx     = "bsc_tx"
+	FieldPolyTx    = "poly_tx"
+	FieldHeight    = "height"
+	FieldSender    = "sender"
+	FieldNonce     = "nonce"
+)
+
+// directions of the relaying
+const (
+	BSCToPoly = "bsc_to_poly"
+	PolyToBSC = "poly_to_bsc"
+)
+
+var fieldOrder = []string{FieldDirection, FieldBSCTx, FieldPolyTx, FieldHeight, FieldSender, FieldNonce}
+
+var jsonLevels = map[int]string{
+	TraceLog: "trace",
+	DebugLog: "debug",
+	InfoLog:  "info",
+	WarnLog:  "warn",
+	ErrorLog: "error",
+	FatalLog: "fatal",
+}
+
+type Fields map[string]interface{}
+
+// Entry logs with a module and fields, the module decides the level
+type Entry struct {
+	module string
+	fields Fields
+}
+
+func Module(module string) *Entry {
+	return &Entry{module: module}
+}
+
+// With returns a copy of the entry with the fields added
+func (e *Entry) With(fields Fields) *Entry {
+	merged := make(Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{module: e.module, fields: merged}
+}
+
+func (e *Entry) Tracef(format string, a ...interface{}) {
+	Log.entryf(TraceLog, e, format, a...)
+}
+
+func (e *Entry) Debugf(format string, a ...interface{}) {
+	Log.entryf(DebugLog, e, format, a...)
+}
+
+func (e *Entry) Infof(format string, a ...interface{}) {
+	Log.entryf(InfoLog, e, format, a...)
+}
+
+func (e *Entry) Warnf(format string, a ...interface{}) {
+	Log.entryf(WarnLog, e, format, a...)
+}
+
+func (e *Entry) Errorf(format string, a ...interface{}) {
+	Log.entryf(ErrorLog, e, format, a...)
+}
+
+func (e *Entry) Fatalf(format string, a ...interface{}) {
+	Log.entryf(FatalLog, e, format, a...)
+}
+
+// ParseLevel reads a level by number or by name like debug
+func ParseLevel(s string) (int, error) {
+	for k, v := range jsonLevels {
+		if strings.EqualFold(s, v) {
+			return k, nil
+		}
+	}
+	level, err := strconv.Atoi(s)
+	if err != nil || level < 0 || level > MaxLevelLog {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// ParseModuleLevels reads levels of modules like "EthSender=debug,tools=3"
+func ParseModuleLevels(s string) (map[string]int, error) {
+	levels := make(map[string]int)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid module level %q, want module=level", item)
+		}
+		level, err := ParseLevel(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(kv[0])] = level
+	}
+	return levels, nil
+}
+
+// ParseFormat reads text or json
+func ParseFormat(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "", "text":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	default:
+		return 0, fmt.Errorf("invalid log format %q, want text or json", s)
+	}
+}
+
+// callers caches the module of each call site, an empty module marks a frame
+// of the logger itself
+var (
+	callers sync.Map
+	logDir  = fileDir(reflect.ValueOf(Module).Pointer())
+)
+
+func fileDir(pc uintptr) string {
+	file, _ := runtime.FuncForPC(pc).FileLine(pc)
+	return filepath.Dir(file)
+}
+
+func isLoggerFrame(f *runtime.Func, pc uintptr) bool {
+	file, _ := f.FileLine(pc)
+	return filepath.Dir(file) == logDir && !strings.HasSuffix(file, "_test.go")
+}
+
+// callerModule is the module of the first caller outside this package: the
+// type of a method like EthSender, otherwise the package like tools
+func callerModule() string {
+	var pcs [8]uintptr
+	n := runtime.Callers(3, pcs[:])
+	for _, pc := range pcs[:n] {
+		if v, ok := callers.Load(pc); ok {
+			if module := v.(string); module != "" {
+				return module
+			}
+			continue
+		}
+		module := ""
+		if f := runtime.FuncForPC(pc - 1); f != nil && !isLoggerFrame(f, pc-1) {
+			module = moduleOf(f.Name())
+		}
+		callers.Store(pc, module)
+		if module != "" {
+			return module
+		}
+	}
+	return ""
+}
+
+// moduleOf maps github.com/x/manager.(*EthSender).sendTxToEth.func1 to EthSender
+// and github.com/x/tools.GetProof.func1 to tools
+func moduleOf(funcName string) string {
+	name := funcName[strings.LastIndex(funcName, "/")+1:]
+	dot := strings.Index(name, ".")
+	if dot < 0 {
+		return name
+	}
+	rest := name[dot+1:]
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.Index(rest, ")"); end > 0 {
+			return strings.TrimPrefix(rest[1:end], "*")
+		}
+	}
+	// a method with value receiver, closures are funcN
+	if parts := strings.Split(rest, "."); len(parts) > 1 && !strings.HasPrefix(parts[1], "func") {
+		return parts[0]
+	}
+	return name[:dot]
+}
+
+func (l *Logger) format(level int, module string, fields Fields, msg string) string {
+	msg = strings.TrimSuffix(msg, "\n")
+	if l.logFormat == JSONFormat {
+		line := make(map[string]interface{}, len(fields)+4)
+		for k, v := range fields {
+			line[k] = v
+		}
+		line["time"] = time.Now().Format("2006-01-02T15:04:05.000000Z07:00")
+		line["level"] = jsonLevels[level]
+		line["msg"] = msg
+		if module != "" {
+			line[FieldModule] = module
+		}
+		data, err := json.Marshal(line)
+		if err != nil {
+			return fmt.Sprintf(`{"level":%q,"msg":%q}`, jsonLevels[level], msg)
+		}
+		return string(data)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(LevelName(level))
+	if module != "" {
+		b.WriteString(" [" + module + "]")
+	}
+	b.WriteString(" " + msg)
+	for _, k := range sortedFields(fields) {
+		fmt.Fprintf(b, " %s=%v", k, fields[k])
+	}
+	return b.String()
+}
+
+// sortedFields puts the fields of the relaying first
+func sortedFields(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for _, k := range fieldOrder {
+		if _, ok := fields[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	others := make([]string, 0)
+	for k := range fields {
+		known := false
+		for _, v := range fieldOrder {
+			known = known || k == v
+		}
+		if !known {
+			others = append(others, k)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The poly network is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The poly network is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+// Levels are the level of the logger and the levels of modules
+type Levels struct {
+	Level   string
+	Modules map[string]string
+}
+
+func (l *Logger) Levels() *Levels {
+	levels := &Levels{Level: jsonLevels[l.Level()], Modules: make(map[string]string)}
+	for k, v := range l.ModuleLevels() {
+		levels.Modules[k] = jsonLevels[v]
+	}
+	return levels
+}
+
+// Verbose makes the logger and every module log one level more, down to trace
+func (l *Logger) Verbose() {
+	l.setLock.Lock()
+	defer l.setLock.Unlock()
+	if level := l.Level(); level > TraceLog {
+		atomic.StoreInt32(&l.level, int32(level-1))
+	}
+	levels := l.ModuleLevels()
+	for k, v := range levels {
+		if v > TraceLog {
+			levels[k] = v - 1
+		}
+	}
+	l.moduleLevels.Store(levels)
+}
+
+// SetLevels replaces the level of the logger and the levels of modules in one
+// change, like SetDebugLevel and SetModuleLevels together
+func (l *Logger) SetLevels(level int, modules map[string]int) error {
+	if level > MaxLevelLog || level < 0 {
+		return errors.New("Invalid Debug Level")
+	}
+	copied, err := copyModuleLevels(modules)
+	if err != nil {
+		return err
+	}
+	l.setLock.Lock()
+	defer l.setLock.Unlock()
+	atomic.StoreInt32(&l.level, int32(level))
+	l.moduleLevels.Store(copied)
+	return nil
+}
+
+// LevelHandler shows the levels on GET and changes them on POST:
+//
+//	level=debug                 sets the level of the logger
+//	module=EthSender&level=3    sets the level of a module, an empty level resets it
+//	modules=EthSender=debug,... replaces the levels of all modules
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut:
+			if err := setLevels(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			Infof("LevelHandler - log levels changed from %s to %+v", r.RemoteAddr, *Log.Levels())
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Log.Levels())
+	})
+}
+
+func setLevels(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if _, ok := r.Form["modules"]; ok {
+		levels, err := ParseModuleLevels(r.Form.Get("modules"))
+		if err != nil {
+			return err
+		}
+		if err = Log.SetModuleLevels(levels); err != nil {
+			return err
+		}
+	}
+	module := r.Form.Get("module")
+	if _, ok := r.Form["level"]; !ok {
+		return nil
+	}
+	if module != "" && r.Form.Get("level") == "" {
+		Log.ResetModuleLevel(module)
+		return nil
+	}
+	level, err := ParseLevel(r.Form.Get("level"))
+	if err != nil {
+		return err
+	}
+	if module != "" {
+		return Log.SetModuleLevel(module, level)
+	}
+	return Log.SetDebugLevel(level)
+}
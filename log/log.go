@@ -30,6 +30,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -76,6 +78,7 @@ const (
 	PATH                 = "./logoutput/"
 )
 
+// GetGID parses the goroutine ID from the stack, it is slow and no longer logged
 func GetGID() uint64 {
 	var buf [64]byte
 	b := buf[:runtime.Stack(buf[:], false)]
@@ -113,17 +116,23 @@ func NameLevel(name string) int {
 }
 
 type Logger struct {
-	level   int
-	logger  *log.Logger
-	logFile *os.File
+	level        int32
+	logFormat    int
+	moduleLevels atomic.Value // map[string]int, replaced as a whole
+	setLock      sync.Mutex   // one change of the module levels at a time
+	logger       *log.Logger
+	logFile      *os.File
+	rotator      *RotateWriter
 }
 
 func New(out io.Writer, prefix string, flag, level int, file *os.File) *Logger {
-	return &Logger{
-		level:   level,
+	l := &Logger{
+		level:   int32(level),
 		logger:  log.New(out, prefix, flag),
 		logFile: file,
 	}
+	l.moduleLevels.Store(map[string]int{})
+	return l
 }
 
 func (l *Logger) SetDebugLevel(level int) error {
@@ -131,34 +140,135 @@ func (l *Logger) SetDebugLevel(level int) error {
 		return errors.New("Invalid Debug Level")
 	}
 
-	l.level = level
+	l.setLock.Lock()
+	atomic.StoreInt32(&l.level, int32(level))
+	l.setLock.Unlock()
 	return nil
 }
 
-func (l *Logger) Output(level int, a ...interface{}) error {
-	if level >= l.level {
-		gid := GetGID()
-		gidStr := strconv.FormatUint(gid, 10)
+func (l *Logger) Level() int {
+	return int(atomic.LoadInt32(&l.level))
+}
 
-		a = append([]interface{}{LevelName(level), "GID",
-			gidStr + ","}, a...)
+// SetFormat switches between TextFormat and JSONFormat, it is not safe to
+// call while logging
+func (l *Logger) SetFormat(format int) error {
+	switch format {
+	case TextFormat:
+		l.logger.SetFlags(log.Ldate | log.Lmicroseconds)
+	case JSONFormat:
+		l.logger.SetFlags(0)
+	default:
+		return errors.New("Invalid Log Format")
+	}
+	l.logFormat = format
+	return nil
+}
 
-		return l.logger.Output(CALL_DEPTH, fmt.Sprintln(a...))
+// SetModuleLevels replaces the levels of modules, a module not in levels
+// logs at the level of the logger
+func (l *Logger) SetModuleLevels(levels map[string]int) error {
+	copied, err := copyModuleLevels(levels)
+	if err != nil {
+		return err
 	}
+	l.setLock.Lock()
+	l.moduleLevels.Store(copied)
+	l.setLock.Unlock()
 	return nil
 }
 
-func (l *Logger) Outputf(level int, format string, v ...interface{}) error {
-	if level >= l.level {
-		gid := GetGID()
-		v = append([]interface{}{LevelName(level), "GID",
-			gid}, v...)
+func copyModuleLevels(levels map[string]int) (map[string]int, error) {
+	copied := make(map[string]int, len(levels))
+	for k, v := range levels {
+		if v > MaxLevelLog || v < 0 {
+			return nil, fmt.Errorf("Invalid Debug Level %d of %s", v, k)
+		}
+		copied[k] = v
+	}
+	return copied, nil
+}
 
-		return l.logger.Output(CALL_DEPTH, fmt.Sprintf("%s %s %d, "+format+"\n", v...))
+// SetModuleLevel sets the level of one module, ResetModuleLevel makes it log
+// at the level of the logger again
+func (l *Logger) SetModuleLevel(module string, level int) error {
+	if level > MaxLevelLog || level < 0 {
+		return fmt.Errorf("Invalid Debug Level %d of %s", level, module)
 	}
+	l.updateModuleLevels(func(levels map[string]int) { levels[module] = level })
 	return nil
 }
 
+func (l *Logger) ResetModuleLevel(module string) {
+	l.updateModuleLevels(func(levels map[string]int) { delete(levels, module) })
+}
+
+func (l *Logger) updateModuleLevels(update func(levels map[string]int)) {
+	l.setLock.Lock()
+	defer l.setLock.Unlock()
+	levels := l.ModuleLevels()
+	update(levels)
+	l.moduleLevels.Store(levels)
+}
+
+// ModuleLevels returns a copy of the levels of modules
+func (l *Logger) ModuleLevels() map[string]int {
+	levels := l.moduleLevels.Load().(map[string]int)
+	copied := make(map[string]int, len(levels))
+	for k, v := range levels {
+		copied[k] = v
+	}
+	return copied
+}
+
+// enabled tells whether the module logs at level. The module of the caller is
+// only looked up if module levels are set or the format needs it.
+func (l *Logger) enabled(level int, module string) (string, bool) {
+	levels := l.moduleLevels.Load().(map[string]int)
+	if module == "" && (len(levels) > 0 || l.logFormat == JSONFormat) {
+		module = callerModule()
+	}
+	if v, ok := levels[module]; ok {
+		return module, level >= v
+	}
+	return module, level >= l.Level()
+}
+
+// minLevel is the lowest level any module logs at
+func (l *Logger) minLevel() int {
+	min := l.Level()
+	for _, v := range l.moduleLevels.Load().(map[string]int) {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func (l *Logger) Output(level int, a ...interface{}) error {
+	module, ok := l.enabled(level, "")
+	if !ok {
+		return nil
+	}
+	return l.logger.Output(CALL_DEPTH, l.format(level, module, nil, fmt.Sprintln(a...)))
+}
+
+func (l *Logger) Outputf(level int, format string, v ...interface{}) error {
+	module, ok := l.enabled(level, "")
+	if !ok {
+		return nil
+	}
+	return l.logger.Output(CALL_DEPTH, l.format(level, module, nil, fmt.Sprintf(format, v...)))
+}
+
+func (l *Logger) entryf(level int, e *Entry, format string, v ...interface{}) error {
+	module, ok := l.enabled(level, e.module)
+	if !ok {
+		return nil
+	}
+	return l.logger.Output(CALL_DEPTH, l.format(level, module, e.fields, fmt.Sprintf(format, v...)))
+}
+
 func (l *Logger) Trace(a ...interface{}) {
 	l.Output(TraceLog, a...)
 }
@@ -208,7 +318,7 @@ func (l *Logger) Fatalf(format string, a ...interface{}) {
 }
 
 func Trace(a ...interface{}) {
-	if TraceLog < Log.level {
+	if TraceLog < Log.minLevel() {
 		return
 	}
 
@@ -228,7 +338,7 @@ func Trace(a ...interface{}) {
 }
 
 func Tracef(format string, a ...interface{}) {
-	if TraceLog < Log.level {
+	if TraceLog < Log.minLevel() {
 		return
 	}
 
@@ -248,7 +358,7 @@ func Tracef(format string, a ...interface{}) {
 }
 
 func Debug(a ...interface{}) {
-	if DebugLog < Log.level {
+	if DebugLog < Log.minLevel() {
 		return
 	}
 
@@ -264,7 +374,7 @@ func Debug(a ...interface{}) {
 }
 
 func Debugf(format string, a ...interface{}) {
-	if DebugLog < Log.level {
+	if DebugLog < Log.minLevel() {
 		return
 	}
 
@@ -341,20 +451,24 @@ func Init(a ...interface{}) {
 
 func InitLog(logLevel int, a ...interface{}) {
 	writers := []io.Writer{}
-	var logFile *os.File
+	var rotator *RotateWriter
 	var err error
 	if len(a) == 0 {
 		writers = append(writers, ioutil.Discard)
 	} else {
 		for _, o := range a {
 			switch o.(type) {
-			case string:
-				logFile, err = FileOpen(o.(string))
+			case string, *RotateConfig:
+				cfg, ok := o.(*RotateConfig)
+				if !ok {
+					cfg = &RotateConfig{Dir: o.(string)}
+				}
+				rotator, err = NewRotateWriter(*cfg)
 				if err != nil {
 					fmt.Println("error: open log file failed")
 					os.Exit(1)
 				}
-				writers = append(writers, logFile)
+				writers = append(writers, rotator)
 			case *os.File:
 				writers = append(writers, o.(*os.File))
 			default:
@@ -364,10 +478,17 @@ func InitLog(logLevel int, a ...interface{}) {
 		}
 	}
 	fileAndStdoutWrite := io.MultiWriter(writers...)
-	Log = New(fileAndStdoutWrite, "", log.Ldate|log.Lmicroseconds, logLevel, logFile)
+	Log = New(fileAndStdoutWrite, "", log.Ldate|log.Lmicroseconds, logLevel, nil)
+	Log.rotator = rotator
 }
 
 func GetLogFileSize() (int64, error) {
+	if Log.rotator != nil {
+		return Log.rotator.Size(), nil
+	}
+	if Log.logFile == nil {
+		return 0, errors.New("no log file")
+	}
 	f, e := Log.logFile.Stat()
 	if e != nil {
 		return 0, e
@@ -384,6 +505,9 @@ func GetMaxLogChangeInterval(maxLogSize int64) int64 {
 }
 
 func CheckIfNeedNewFile() bool {
+	if Log.rotator != nil {
+		return Log.rotator.NeedRotate()
+	}
 	logFileSize, err := GetLogFileSize()
 	maxLogFileSize := GetMaxLogChangeInterval(0)
 	if err != nil {
@@ -398,6 +522,9 @@ func CheckIfNeedNewFile() bool {
 
 func ClosePrintLog() error {
 	var err error
+	if Log.rotator != nil {
+		err = Log.rotator.Close()
+	}
 	if Log.logFile != nil {
 		err = Log.logFile.Close()
 	}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The poly network is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The poly network is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSender struct{}
+
+func (this *testSender) send() {
+	Debugf("sending")
+}
+
+func captureLog(t *testing.T, level, format int) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	old := Log
+	Log = New(buf, "", 0, level, nil)
+	assert.NoError(t, Log.SetFormat(format))
+	t.Cleanup(func() { Log = old })
+	return buf
+}
+
+func TestModuleOf(t *testing.T) {
+	assert.Equal(t, "EthSender", moduleOf("github.com/polynetwork/bsc-relayer/manager.(*EthSender).sendTxToEth.func1"))
+	assert.Equal(t, "sendQueue", moduleOf("github.com/polynetwork/bsc-relayer/manager.sendQueue.push"))
+	assert.Equal(t, "tools", moduleOf("github.com/polynetwork/bsc-relayer/tools.GetProof"))
+	assert.Equal(t, "tools", moduleOf("github.com/polynetwork/bsc-relayer/tools.GetProof.func1"))
+	assert.Equal(t, "main", moduleOf("main.startServer"))
+}
+
+func TestParseModuleLevels(t *testing.T) {
+	levels, err := ParseModuleLevels("EthSender=debug, tools=3,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"EthSender": DebugLog, "tools": WarnLog}, levels)
+
+	_, err = ParseModuleLevels("EthSender")
+	assert.Error(t, err)
+	_, err = ParseModuleLevels("EthSender=verbose")
+	assert.Error(t, err)
+}
+
+func TestModuleLevels(t *testing.T) {
+	buf := captureLog(t, InfoLog, TextFormat)
+
+	(&testSender{}).send()
+	Module("BSCManager").Debugf("hidden")
+	assert.Empty(t, buf.String())
+
+	assert.NoError(t, Log.SetModuleLevels(map[string]int{"testSender": DebugLog, "BSCManager": WarnLog}))
+	(&testSender{}).send()
+	Module("BSCManager").Infof("hidden")
+	Module("EthSender").Infof("shown")
+	out := buf.String()
+	assert.Contains(t, out, "[testSender]")
+	assert.Contains(t, out, "sending")
+	assert.NotContains(t, out, "hidden")
+	assert.Contains(t, out, "[EthSender] shown")
+}
+
+func TestSetLevels(t *testing.T) {
+	captureLog(t, InfoLog, TextFormat)
+
+	assert.NoError(t, Log.SetLevels(WarnLog, map[string]int{"EthSender": DebugLog}))
+	assert.Equal(t, WarnLog, Log.Level())
+	assert.Equal(t, map[string]int{"EthSender": DebugLog}, Log.ModuleLevels())
+
+	// nothing is changed by invalid levels
+	assert.Error(t, Log.SetLevels(MaxLevelLog+1, nil))
+	assert.Error(t, Log.SetLevels(InfoLog, map[string]int{"EthSender": -1}))
+	assert.Equal(t, WarnLog, Log.Level())
+	assert.Equal(t, map[string]int{"EthSender": DebugLog}, Log.ModuleLevels())
+}
+
+func TestJSONFormat(t *testing.T) {
+	buf := captureLog(t, InfoLog, JSONFormat)
+
+	Module("EthSender").With(Fields{FieldPolyTx: "ab", FieldNonce: uint64(7)}).
+		With(Fields{FieldDirection: PolyToBSC}).Warnf("relay %d", 1)
+	Infof("plain")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	line := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, "warn", line["level"])
+	assert.Equal(t, "EthSender", line[FieldModule])
+	assert.Equal(t, "relay 1", line["msg"])
+	assert.Equal(t, "ab", line[FieldPolyTx])
+	assert.Equal(t, float64(7), line[FieldNonce])
+	assert.Equal(t, PolyToBSC, line[FieldDirection])
+	assert.NotEmpty(t, line["time"])
+
+	line = make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &line))
+	assert.Equal(t, "log", line[FieldModule])
+}
+
+func TestLevelHandler(t *testing.T) {
+	captureLog(t, InfoLog, TextFormat)
+	handler := LevelHandler()
+	call := func(method, query string) (int, *Levels) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/loglevel?"+query, nil))
+		levels := new(Levels)
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), levels))
+		}
+		return rec.Code, levels
+	}
+
+	code, levels := call(http.MethodPost, "level=warn")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "warn", levels.Level)
+
+	_, levels = call(http.MethodPost, "module=EthSender&level=1")
+	assert.Equal(t, map[string]string{"EthSender": "debug"}, levels.Modules)
+	_, levels = call(http.MethodPost, "modules=tools=trace,BSCManager=error")
+	assert.Equal(t, map[string]string{"tools": "trace", "BSCManager": "error"}, levels.Modules)
+	_, levels = call(http.MethodPost, "module=tools&level=")
+	assert.Equal(t, map[string]string{"BSCManager": "error"}, levels.Modules)
+
+	Log.Verbose()
+	_, levels = call(http.MethodGet, "")
+	assert.Equal(t, "info", levels.Level)
+	assert.Equal(t, map[string]string{"BSCManager": "warn"}, levels.Modules)
+
+	code, _ = call(http.MethodPost, "level=loud")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = call(http.MethodDelete, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
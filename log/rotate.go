@@ -0,0 +1,264 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The poly network is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The poly network is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LOG_FILE_SUFFIX = "_LOG.log"
+	GZIP_SUFFIX     = ".gz"
+)
+
+// RotateConfig decides when the log file under Dir is replaced by a new one
+// and how many of the old ones are kept
+type RotateConfig struct {
+	Dir      string
+	MaxSize  int64         // bytes, DEFAULT_MAX_LOG_SIZE MB if 0
+	Interval time.Duration // a new file after this time, no time based rotation if 0
+	MaxFiles int           // rotated files kept, all if 0
+	Compress bool          // gzip the rotated files
+}
+
+// RotateWriter writes the log file and rotates it, it is safe for concurrent use
+type RotateWriter struct {
+	cfg RotateConfig
+	now func() time.Time
+
+	lock   sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	cleanLock sync.Mutex // one clean up of the rotated files at a time
+	cleaning  sync.WaitGroup
+}
+
+func NewRotateWriter(cfg RotateConfig) (*RotateWriter, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = GetMaxLogChangeInterval(0)
+	}
+	w := &RotateWriter{cfg: cfg, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.needRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			// keep logging to the old file
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Size is the size of the current file
+func (w *RotateWriter) Size() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.size
+}
+
+// NeedRotate tells whether the next write starts a new file
+func (w *RotateWriter) NeedRotate() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.needRotate(1)
+}
+
+// Rotate starts a new file now
+func (w *RotateWriter) Rotate() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.rotate()
+}
+
+// Close closes the file and waits for the clean up of the rotated files
+func (w *RotateWriter) Close() error {
+	w.lock.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.lock.Unlock()
+	w.cleaning.Wait()
+	return err
+}
+
+func (w *RotateWriter) needRotate(n int64) bool {
+	if w.size > 0 && w.size+n > w.cfg.MaxSize {
+		return true
+	}
+	return w.cfg.Interval > 0 && w.now().Sub(w.opened) >= w.cfg.Interval
+}
+
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(w.cfg.Dir, 0766); err != nil {
+		return err
+	}
+	now := w.now()
+	base := filepath.Join(w.cfg.Dir, now.Format("2006-01-02_15.04.05"))
+	name := base + LOG_FILE_SUFFIX
+	// two files in the same second
+	for i := 1; fileExists(name) || fileExists(name+GZIP_SUFFIX); i++ {
+		name = fmt.Sprintf("%s.%d%s", base, i, LOG_FILE_SUFFIX)
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	w.file, w.size, w.opened = file, 0, now
+	return nil
+}
+
+func (w *RotateWriter) rotate() error {
+	old := w.file
+	if err := w.open(); err != nil {
+		return err
+	}
+	if err := old.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "log rotation failed to close %s: %v\n", old.Name(), err)
+	}
+	active := w.file.Name()
+	w.cleaning.Add(1)
+	go func() {
+		defer w.cleaning.Done()
+		w.clean(old.Name(), active)
+	}()
+	return nil
+}
+
+// clean compresses the rotated file and removes the oldest ones beyond MaxFiles
+func (w *RotateWriter) clean(rotated, active string) {
+	w.cleanLock.Lock()
+	defer w.cleanLock.Unlock()
+
+	if w.cfg.Compress {
+		if err := compressFile(rotated); err != nil {
+			fmt.Fprintf(os.Stderr, "log rotation failed to compress %s: %v\n", rotated, err)
+		}
+	}
+	if w.cfg.MaxFiles <= 0 {
+		return
+	}
+	files, err := rotatedFiles(w.cfg.Dir, active)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log rotation failed to list %s: %v\n", w.cfg.Dir, err)
+		return
+	}
+	for len(files) > w.cfg.MaxFiles {
+		if err := os.Remove(files[0].name); err != nil {
+			fmt.Fprintf(os.Stderr, "log rotation failed to remove %s: %v\n", files[0].name, err)
+		}
+		files = files[1:]
+	}
+}
+
+type logFile struct {
+	name    string
+	modTime time.Time
+}
+
+// rotatedFiles are the log files under dir other than active, oldest first
+func rotatedFiles(dir, active string) ([]logFile, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]logFile, 0)
+	for _, info := range infos {
+		name := filepath.Join(dir, info.Name())
+		if info.IsDir() || name == active || !(strings.HasSuffix(name, LOG_FILE_SUFFIX) ||
+			strings.HasSuffix(name, LOG_FILE_SUFFIX+GZIP_SUFFIX)) {
+			continue
+		}
+		files = append(files, logFile{name: name, modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime.Equal(files[j].modTime) {
+			return files[i].name < files[j].name
+		}
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	return files, nil
+}
+
+// compressFile replaces the file by name.gz with the same modification time
+func compressFile(name string) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := name + GZIP_SUFFIX + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+GZIP_SUFFIX)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
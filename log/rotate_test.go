@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The poly network is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The poly network is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readLines reads the lines of a log file, compressed or not
+func readLines(t *testing.T, name string) []string {
+	f, err := os.Open(name)
+	assert.NoError(t, err)
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, GZIP_SUFFIX) {
+		zr, err := gzip.NewReader(f)
+		assert.NoError(t, err)
+		r = zr
+	}
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func logFiles(t *testing.T, dir string) []string {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+LOG_FILE_SUFFIX+"*"))
+	assert.NoError(t, err)
+	return names
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewRotateWriter(RotateConfig{Dir: dir, MaxSize: 1000, Compress: true})
+	assert.NoError(t, err)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				fmt.Fprintf(w, "goroutine %d line %03d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.NoError(t, w.Close())
+
+	seen := make(map[string]bool)
+	compressed := 0
+	for _, name := range logFiles(t, dir) {
+		info, err := os.Stat(name)
+		assert.NoError(t, err)
+		if strings.HasSuffix(name, GZIP_SUFFIX) {
+			compressed++
+		} else {
+			assert.True(t, info.Size() <= 1000)
+		}
+		for _, line := range readLines(t, name) {
+			assert.False(t, seen[line], line)
+			seen[line] = true
+		}
+	}
+	// every line is written once and only the current file is not compressed
+	assert.Len(t, seen, 800)
+	assert.Equal(t, len(logFiles(t, dir))-1, compressed)
+	assert.True(t, compressed > 10)
+}
+
+func TestRotateByTimeWithRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := &RotateWriter{cfg: RotateConfig{Dir: dir, MaxSize: 1 << 20, Interval: time.Hour, MaxFiles: 2},
+		now: func() time.Time { return now }}
+	assert.NoError(t, w.open())
+
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(w, "hour %d\n", i)
+		now = now.Add(30 * time.Minute)
+		fmt.Fprintf(w, "hour %d and a half\n", i)
+		now = now.Add(30 * time.Minute)
+		// no clean up in parallel, the files have to keep their order
+		w.cleaning.Wait()
+		time.Sleep(10 * time.Millisecond)
+	}
+	fmt.Fprintf(w, "hour 5\n")
+	assert.NoError(t, w.Close())
+
+	names := logFiles(t, dir)
+	// the current file and the two last rotated ones
+	assert.Len(t, names, 3)
+	assert.Equal(t, []string{"hour 3", "hour 3 and a half"}, readLines(t, filepath.Join(dir, "2020-01-01_03.00.00"+LOG_FILE_SUFFIX)))
+	assert.Equal(t, []string{"hour 5"}, readLines(t, filepath.Join(dir, "2020-01-01_05.00.00"+LOG_FILE_SUFFIX)))
+	_, err = os.Stat(filepath.Join(dir, "2020-01-01_02.00.00"+LOG_FILE_SUFFIX))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInitLogWithRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	old := Log
+	defer func() { Log = old }()
+
+	InitLog(InfoLog, &RotateConfig{Dir: dir, MaxSize: 200})
+	for i := 0; i < 10; i++ {
+		Infof("line %d", i)
+	}
+	size, err := GetLogFileSize()
+	assert.NoError(t, err)
+	assert.True(t, size > 0 && size <= 200)
+	assert.NoError(t, ClosePrintLog())
+	assert.True(t, len(logFiles(t, dir)) > 1)
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The poly network is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The poly network is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchLevelSignals makes SIGUSR1 log one level more and SIGUSR2 restore the
+// levels set at the time of the call
+func WatchLevelSignals() {
+	level, modules := Log.Level(), Log.ModuleLevels()
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range sc {
+			switch sig {
+			case syscall.SIGUSR1:
+				Log.Verbose()
+			case syscall.SIGUSR2:
+				Log.SetLevels(level, modules)
+			}
+			Infof("WatchLevelSignals - log levels changed by %s to %+v", sig, *Log.Levels())
+		}
+	}()
+}
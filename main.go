@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
@@ -29,8 +30,11 @@ import (
 	"github.com/polynetwork/bsc-relayer/cmd"
 	"github.com/polynetwork/bsc-relayer/config"
 	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/events"
+	"github.com/polynetwork/bsc-relayer/feemock"
 	"github.com/polynetwork/bsc-relayer/log"
 	"github.com/polynetwork/bsc-relayer/manager"
+	"github.com/polynetwork/bsc-relayer/metrics"
 	sdk "github.com/polynetwork/poly-go-sdk"
 	"github.com/urfave/cli"
 )
@@ -54,10 +58,27 @@ func setupApp() *cli.App {
 		cmd.BSCStartForceFlag,
 		cmd.PolyStartFlag,
 		cmd.LogDir,
+		cmd.LogMaxSizeFlag,
+		cmd.LogRotateFlag,
+		cmd.LogMaxFilesFlag,
+		cmd.LogCompressFlag,
+		cmd.LogFormatFlag,
+		cmd.LogModuleLevelsFlag,
+		cmd.FeeMockFlag,
+		cmd.MasterKeyFileFlag,
+	}
+	app.Commands = []cli.Command{
+		cmd.SpeedUpCommand,
+		cmd.CancelCommand,
+		cmd.AccountsCommand,
+		cmd.EncryptCommand,
+		cmd.PolyWalletCommand,
+		cmd.RegisterRelayerCommand,
+		cmd.LookupCommand,
 	}
-	app.Commands = []cli.Command{}
 	app.Before = func(context *cli.Context) error {
 		runtime.GOMAXPROCS(runtime.NumCPU())
+		config.MasterKeyFile = context.GlobalString(cmd.GetFlagName(cmd.MasterKeyFileFlag))
 		return nil
 	}
 	return app
@@ -78,7 +99,31 @@ func startServer(ctx *cli.Context) {
 	logLevel := ctx.GlobalInt(cmd.GetFlagName(cmd.LogLevelFlag))
 
 	ld := ctx.GlobalString(cmd.GetFlagName(cmd.LogDir))
-	log.InitLog(logLevel, ld, log.Stdout)
+	log.InitLog(logLevel, &log.RotateConfig{
+		Dir:      ld,
+		MaxSize:  ctx.GlobalInt64(cmd.GetFlagName(cmd.LogMaxSizeFlag)) * log.BYTE_TO_MB,
+		Interval: ctx.GlobalDuration(cmd.GetFlagName(cmd.LogRotateFlag)),
+		MaxFiles: ctx.GlobalInt(cmd.GetFlagName(cmd.LogMaxFilesFlag)),
+		Compress: ctx.GlobalBool(cmd.GetFlagName(cmd.LogCompressFlag)),
+	}, log.Stdout)
+	defer log.ClosePrintLog()
+	logFormat, err := log.ParseFormat(ctx.GlobalString(cmd.GetFlagName(cmd.LogFormatFlag)))
+	if err == nil {
+		err = log.Log.SetFormat(logFormat)
+	}
+	if err != nil {
+		log.Errorf("startServer - %v", err)
+		return
+	}
+	moduleLevels, err := log.ParseModuleLevels(ctx.GlobalString(cmd.GetFlagName(cmd.LogModuleLevelsFlag)))
+	if err == nil {
+		err = log.Log.SetModuleLevels(moduleLevels)
+	}
+	if err != nil {
+		log.Errorf("startServer - %v", err)
+		return
+	}
+	log.WatchLevelSignals()
 
 	ConfigPath = ctx.GlobalString(cmd.GetFlagName(cmd.ConfigPathFlag))
 	StartHeight = ctx.GlobalUint64(cmd.GetFlagName(cmd.BSCStartFlag))
@@ -95,7 +140,7 @@ func startServer(ctx *cli.Context) {
 
 	// create poly sdk
 	polySdk := sdk.NewPolySdk()
-	err := setUpPoly(polySdk, servConfig.PolyConfig.RestURL)
+	err = setUpPoly(polySdk, servConfig.PolyConfig.RestURL)
 	if err != nil {
 		log.Errorf("startServer - failed to setup poly sdk: %v", err)
 		return
@@ -119,20 +164,78 @@ func startServer(ctx *cli.Context) {
 		return
 	}
 
+	if servConfig.MetricsAddr != "" {
+		go func() {
+			if err := metrics.Serve(servConfig.MetricsAddr); err != nil {
+				log.Errorf("startServer - metrics server stopped: %v", err)
+			}
+		}()
+	}
+	if servConfig.EventsConfig != nil {
+		sinks, err := events.NewSinks(servConfig.EventsConfig)
+		if err != nil {
+			log.Errorf("startServer - %v", err)
+			return
+		}
+		publisher, err := events.NewPublisher(boltDB, sinks...)
+		if err != nil {
+			log.Errorf("startServer - failed to start events: %v", err)
+			return
+		}
+		events.SetDefault(publisher)
+		if servConfig.EventsConfig.SSEAddr != "" {
+			go func() {
+				if err := publisher.Serve(servConfig.EventsConfig.SSEAddr); err != nil {
+					log.Errorf("startServer - events server stopped: %v", err)
+				}
+			}()
+		}
+	}
+
+	if rulesFile := ctx.GlobalString(cmd.GetFlagName(cmd.FeeMockFlag)); rulesFile != "" {
+		rules, err := feemock.LoadRules(rulesFile)
+		if err != nil {
+			log.Errorf("startServer - %v", err)
+			return
+		}
+		url, err := feemock.NewServer(rules).Start("127.0.0.1:0")
+		if err != nil {
+			log.Errorf("startServer - failed to start fee mock: %v", err)
+			return
+		}
+		log.Warnf("startServer - fee checks are answered by the fee mock at %s from %s", url, rulesFile)
+		servConfig.BridgeConfig.RestURL = [][]string{{url}}
+	}
+
 	bridgeSdk := poly_bridge_sdk.NewBridgeFeeCheck(servConfig.BridgeConfig.RestURL, 5)
 
-	initPolyServer(servConfig, polySdk, ethereumsdk, bridgeSdk, boltDB)
+	polyMgr := initPolyServer(servConfig, polySdk, ethereumsdk, bridgeSdk, boltDB)
 	initBSCServer(servConfig, polySdk, ethereumsdk, boltDB)
+	if servConfig.AdminAddr != "" {
+		if err := servConfig.CheckAdmin(); err != nil {
+			log.Errorf("startServer - %v", err)
+			return
+		}
+		go func() {
+			if err := serveAdmin(servConfig, boltDB, polyMgr); err != nil {
+				log.Errorf("startServer - admin server stopped: %v", err)
+			}
+		}()
+	}
 	waitToExit()
 }
 
-func initPolyServer(servConfig *config.ServiceConfig, polysdk *sdk.PolySdk, ethereumsdk *ethclient.Client, bridgeSdk *poly_bridge_sdk.BridgeFeeCheck, boltDB *db.BoltDB) {
+func initPolyServer(servConfig *config.ServiceConfig, polysdk *sdk.PolySdk, ethereumsdk *ethclient.Client, bridgeSdk *poly_bridge_sdk.BridgeFeeCheck, boltDB *db.BoltDB) *manager.PolyManager {
 	mgr, err := manager.NewPolyManager(servConfig, uint32(PolyStartHeight), polysdk, ethereumsdk, bridgeSdk, boltDB)
 	if err != nil {
 		log.Fatalf("initPolyServer - PolyServer service start failed: %v", err)
-		return
+		return nil
 	}
 	go mgr.MonitorChain()
+	go mgr.MonitorFee()
+	go mgr.MonitorSenders()
+	go mgr.MonitorBalances()
+	return mgr
 }
 
 func initBSCServer(servConfig *config.ServiceConfig, polysdk *sdk.PolySdk, ethereumsdk *ethclient.Client, boltDB *db.BoltDB) {
@@ -144,6 +247,18 @@ func initBSCServer(servConfig *config.ServiceConfig, polysdk *sdk.PolySdk, ether
 	go mgr.MonitorChain()
 	go mgr.MonitorDeposit()
 	go mgr.CheckDeposit()
+	go mgr.MonitorRelayer()
+}
+
+// serveAdmin exposes the admin endpoints on AdminAddr, it blocks like http.ListenAndServe
+func serveAdmin(servConfig *config.ServiceConfig, boltDB *db.BoltDB, polyMgr *manager.PolyManager) error {
+	mux := http.NewServeMux()
+	mux.Handle("/loglevel", log.LevelHandler())
+	mux.Handle("/lookup", manager.LifecycleHandler(boltDB))
+	if polyMgr != nil {
+		mux.Handle("/tx", polyMgr.TxHandler())
+	}
+	return http.ListenAndServe(servConfig.AdminAddr, manager.AdminAuth(servConfig.AdminToken, mux))
 }
 
 func waitToExit() {
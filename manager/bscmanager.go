@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -41,6 +42,7 @@ import (
 	"github.com/polynetwork/bsc-relayer/tools"
 	sdk "github.com/polynetwork/poly-go-sdk"
 	"github.com/polynetwork/poly/common"
+	ptypes "github.com/polynetwork/poly/core/types"
 	"github.com/polynetwork/poly/native/service/cross_chain_manager/eth"
 	scom "github.com/polynetwork/poly/native/service/header_sync/common"
 	autils "github.com/polynetwork/poly/native/service/utils"
@@ -91,6 +93,18 @@ func (this *CrossTransfer) Deserialization(source *common.ZeroCopySource) error
 	return nil
 }
 
+// polyAccount is a poly signer with its address
+type polyAccount struct {
+	signer  sdk.Signer
+	address common.Address
+}
+
+// logger logs the import of the bsc tx by the account
+func (this *polyAccount) logger(bscTx []byte) *log.Entry {
+	return log.Module("BSCManager").With(log.Fields{log.FieldDirection: log.BSCToPoly,
+		log.FieldSender: this.address.ToBase58(), log.FieldBSCTx: ethcommon.BytesToHash(bscTx).String()})
+}
+
 type BSCManager struct {
 	config         *config.ServiceConfig
 	restClient     *tools.RestClient
@@ -100,7 +114,9 @@ type BSCManager struct {
 	forceHeight    uint64
 	lockerContract *bind.BoundContract
 	polySdk        *sdk.PolySdk
-	polySigner     *sdk.Account
+	polySigner     sdk.Signer
+	polyAddress    common.Address
+	proofSigners   []*polyAccount
 	exitChan       chan int
 	header4sync    [][]byte
 	crosstx4sync   []*CrossTransfer
@@ -108,34 +124,15 @@ type BSCManager struct {
 }
 
 func NewBSCManager(servconfig *config.ServiceConfig, startheight uint64, startforceheight uint64, ontsdk *sdk.PolySdk, client *ethclient.Client, boltDB *db.BoltDB) (*BSCManager, error) {
-	var wallet *sdk.Wallet
-	var err error
-	if !common.FileExisted(servconfig.PolyConfig.WalletFile) {
-		wallet, err = ontsdk.CreateWallet(servconfig.PolyConfig.WalletFile)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		wallet, err = ontsdk.OpenWallet(servconfig.PolyConfig.WalletFile)
-		if err != nil {
-			log.Errorf("NewBSCManager - wallet open error: %s", err.Error())
-			return nil, err
-		}
+	signer, address, err := tools.NewPolySigner(servconfig.PolyConfig, ontsdk)
+	if err != nil {
+		return nil, err
 	}
-	signer, err := wallet.GetDefaultAccount([]byte(servconfig.PolyConfig.WalletPwd))
-	if err != nil || signer == nil {
-		signer, err = wallet.NewDefaultSettingAccount([]byte(servconfig.PolyConfig.WalletPwd))
-		if err != nil {
-			log.Errorf("NewBSCManager - wallet password error")
-			return nil, err
-		}
-
-		err = wallet.Save()
-		if err != nil {
-			return nil, err
-		}
+	log.Infof("NewBSCManager - poly address: %s", address.ToBase58())
+	proofSigners, err := newProofSigners(servconfig, ontsdk, signer, address)
+	if err != nil {
+		return nil, err
 	}
-	log.Infof("NewBSCManager - poly address: %s", signer.Address.ToBase58())
 
 	mgr := &BSCManager{
 		config:        servconfig,
@@ -146,10 +143,15 @@ func NewBSCManager(servconfig *config.ServiceConfig, startheight uint64, startfo
 		client:        client,
 		polySdk:       ontsdk,
 		polySigner:    signer,
+		polyAddress:   address,
+		proofSigners:  proofSigners,
 		header4sync:   make([][]byte, 0),
 		crosstx4sync:  make([]*CrossTransfer, 0),
 		db:            boltDB,
 	}
+	if err = checkPolyRelayer(servconfig, ontsdk, mgr.polyAddresses()...); err != nil {
+		return nil, err
+	}
 	err = mgr.init()
 	if err != nil {
 		return nil, err
@@ -158,6 +160,28 @@ func NewBSCManager(servconfig *config.ServiceConfig, startheight uint64, startfo
 	}
 }
 
+// newProofSigners are the ProofAccounts importing the proofs, the header
+// signer does it itself if there are none
+func newProofSigners(servconfig *config.ServiceConfig, polySdk *sdk.PolySdk, signer sdk.Signer,
+	address common.Address) ([]*polyAccount, error) {
+	accs, err := tools.NewPolyProofSigners(servconfig.PolyConfig, polySdk)
+	if err != nil {
+		return nil, err
+	}
+	if len(accs) == 0 {
+		return []*polyAccount{{signer: signer, address: address}}, nil
+	}
+	proofSigners := make([]*polyAccount, 0, len(accs))
+	for _, v := range accs {
+		if v.Address == address {
+			return nil, fmt.Errorf("proof account %s is the header sync account", address.ToBase58())
+		}
+		proofSigners = append(proofSigners, &polyAccount{signer: v, address: v.Address})
+		log.Infof("NewBSCManager - poly proof account: %s", v.Address.ToBase58())
+	}
+	return proofSigners, nil
+}
+
 func (this *BSCManager) MonitorChain() {
 	fetchBlockTicker := time.NewTicker(config.BSC_MONITOR_INTERVAL)
 	var (
@@ -202,6 +226,7 @@ func (this *BSCManager) MonitorChain() {
 		}
 	}
 }
+
 func (this *BSCManager) init() error {
 	// get latest height
 	latestHeight := this.findLastestHeight()
@@ -297,11 +322,11 @@ func (this *BSCManager) fetchLockDepositEvents(height uint64, client *ethclient.
 			for _, v := range this.config.TargetContracts {
 				toChainIdArr, ok := v[toContractStr]
 				if ok {
-					if len(toChainIdArr["outbound"]) == 0 {
+					if toChainIdArr == nil || len(toChainIdArr.Outbound) == 0 {
 						isTarget = true
 						break
 					}
-					for _, id := range toChainIdArr["outbound"] {
+					for _, id := range toChainIdArr.Outbound {
 						if id == evt.ToChainId {
 							isTarget = true
 							break
@@ -312,14 +337,18 @@ func (this *BSCManager) fetchLockDepositEvents(height uint64, client *ethclient.
 					}
 				}
 			}
-			if !isTarget {
-				continue
-			}
 		}
 		param := &common2.MakeTxParam{}
 		_ = param.Deserialization(common.NewZeroCopySource([]byte(evt.Rawdata)))
+		if len(this.config.TargetContracts) > 0 && !isTarget {
+			recordFiltered(log.BSCToPoly, evt.Raw.TxHash.Hex(), hex.EncodeToString(param.CrossChainID), height,
+				"not a TargetContracts transfer")
+			continue
+		}
 		if !this.config.IsWhitelistMethod(param.Method) {
 			log.Errorf("target contract method invalid %s", param.Method)
+			recordFiltered(log.BSCToPoly, evt.Raw.TxHash.Hex(), hex.EncodeToString(param.CrossChainID), height,
+				"method "+param.Method+" is not in WhitelistMethods")
 			continue
 		}
 		raw, _ := this.polySdk.GetStorage(autils.CrossChainManagerContractAddress.ToHexString(),
@@ -345,6 +374,8 @@ func (this *BSCManager) fetchLockDepositEvents(height uint64, client *ethclient.
 		if err != nil {
 			log.Errorf("fetchLockDepositEvents - this.db.PutRetry error: %s", err)
 		}
+		recordStage(this.db, bscToPolyRecord(crossTx), &db.LifecycleStage{Stage: stageEventSeen, Height: height,
+			BlockHash: evt.Raw.BlockHash.Hex(), TxHash: evt.Raw.TxHash.Hex()})
 		log.Infof("fetchLockDepositEvent -  height: %d", height)
 	}
 	return true
@@ -352,12 +383,15 @@ func (this *BSCManager) fetchLockDepositEvents(height uint64, client *ethclient.
 
 func (this *BSCManager) commitHeader() int {
 	start := time.Now()
-	tx, err := this.polySdk.Native.Hs.SyncBlockHeader(
+	tx, err := this.polySdk.Native.Hs.NewSyncBlockHeaderTransaction(
 		this.config.BSCConfig.SideChainId,
-		this.polySigner.Address,
+		this.polyAddress,
 		this.header4sync,
-		this.polySigner,
 	)
+	var hash common.Uint256
+	if err == nil {
+		hash, err = this.sendPolyTx(this.polySigner, tx)
+	}
 	if err != nil {
 		errDesc := err.Error()
 		if strings.Contains(errDesc, "get the parent block failed") || strings.Contains(errDesc, "missing required field") {
@@ -372,7 +406,7 @@ func (this *BSCManager) commitHeader() int {
 
 	var h uint32
 	for {
-		h, _ = this.polySdk.GetBlockHeightByTxHash(tx.ToHexString())
+		h, _ = this.polySdk.GetBlockHeightByTxHash(hash.ToHexString())
 		curr, _ := this.polySdk.GetCurrentBlockHeight()
 		if h > 0 && curr > h {
 			break
@@ -380,7 +414,7 @@ func (this *BSCManager) commitHeader() int {
 		log.Infof("BSCManager SyncBlockHeader wait duration %s", time.Now().Sub(start).String())
 		time.Sleep(time.Second)
 	}
-	log.Infof("BSCManager MonitorChain - commitHeader - send transaction %s to poly chain and confirmed on height %d, synced bsc height %d, bsc height %d, took %s, header count %d", tx.ToHexString(), h, this.currentHeight, this.height, time.Now().Sub(start).String(), len(this.header4sync))
+	log.Infof("BSCManager MonitorChain - commitHeader - send transaction %s to poly chain and confirmed on height %d, synced bsc height %d, bsc height %d, took %s, header count %d", hash.ToHexString(), h, this.currentHeight, this.height, time.Now().Sub(start).String(), len(this.header4sync))
 	this.header4sync = make([][]byte, 0)
 	return 0
 }
@@ -429,94 +463,133 @@ func (this *BSCManager) MonitorDeposit() {
 		}
 	}
 }
+
+// handleLockDepositEvents imports the proofs of the retries, each proof signer
+// takes the next retry once it is done with its last one
 func (this *BSCManager) handleLockDepositEvents(refHeight uint64) error {
 
 	retryList, err := this.db.GetAllRetry()
 	if err != nil {
 		return fmt.Errorf("handleLockDepositEvents - this.db.GetAllRetry error: %s", err)
 	}
+	retries := make(chan []byte)
+	wg := &sync.WaitGroup{}
+	for _, acc := range this.proofSigners {
+		wg.Add(1)
+		go func(acc *polyAccount) {
+			defer wg.Done()
+			for v := range retries {
+				this.handleRetry(acc, v, refHeight)
+			}
+		}(acc)
+	}
 	for _, v := range retryList {
-		// time.Sleep(time.Second * 1)
-		crosstx := new(CrossTransfer)
-		err := crosstx.Deserialization(common.NewZeroCopySource(v))
-		if err != nil {
-			log.Errorf("handleLockDepositEvents - retry.Deserialization error: %s", err)
-			continue
-		}
-		//1. decode events
-		key := crosstx.txIndex
-		keyBytes, err := eth.MappingKeyAt(key, "01")
-		if err != nil {
-			log.Errorf("handleLockDepositEvents - MappingKeyAt error:%s\n", err.Error())
-			continue
-		}
-		if refHeight <= crosstx.height+this.config.BSCConfig.BlockConfig {
-			continue
-		}
-		height := int64(refHeight - this.config.BSCConfig.BlockConfig)
-		heightHex := hexutil.EncodeBig(big.NewInt(height))
-		proofKey := hexutil.Encode(keyBytes)
-		time1 := time.Now()
-		//2. get proof
-		proof, err := tools.GetProof(this.config.BSCConfig.URL(), this.config.BSCConfig.ECCDContractAddress, proofKey, heightHex, this.restClient)
-		if err != nil {
-			log.Errorf("handleLockDepositEvents - error :%s\n", err.Error())
-			continue
-		}
-		time2 := time.Now()
-		//3. commit proof to poly
+		retries <- v
+	}
+	close(retries)
+	wg.Wait()
+	return nil
+}
 
-		txHash, err := this.commitProof(uint32(height), proof, crosstx.value, crosstx.txId)
-		time3 := time.Now()
-		log.Infof("tools.GetProof took %s commitProof took %s", time2.Sub(time1).String(), time3.Sub(time2).String())
-		if err != nil {
-			if strings.Contains(err.Error(), "chooseUtxos, current utxo is not enough") {
-				log.Infof("handleLockDepositEvents - invokeNativeContract error: %s", err)
-				continue
-			} else {
-				if strings.Contains(err.Error(), "tx already done") {
-					log.Debugf("handleLockDepositEvents - eth_tx %s already on poly", ethcommon.BytesToHash(crosstx.txId).String())
-					if err := this.db.DeleteRetry(v); err != nil {
-						log.Errorf("handleLockDepositEvents - this.db.DeleteRetry error: %s", err)
-					}
-				} else {
-					log.Errorf("handleLockDepositEvents - invokeNativeContract error for eth_tx %s: %s", ethcommon.BytesToHash(crosstx.txId).String(), err)
+func (this *BSCManager) handleRetry(acc *polyAccount, v []byte, refHeight uint64) {
+	// time.Sleep(time.Second * 1)
+	crosstx := new(CrossTransfer)
+	err := crosstx.Deserialization(common.NewZeroCopySource(v))
+	if err != nil {
+		log.Errorf("handleLockDepositEvents - retry.Deserialization error: %s", err)
+		return
+	}
+	//1. decode events
+	key := crosstx.txIndex
+	keyBytes, err := eth.MappingKeyAt(key, "01")
+	if err != nil {
+		log.Errorf("handleLockDepositEvents - MappingKeyAt error:%s\n", err.Error())
+		return
+	}
+	if refHeight <= crosstx.height+this.config.BSCConfig.BlockConfig {
+		return
+	}
+	height := int64(refHeight - this.config.BSCConfig.BlockConfig)
+	heightHex := hexutil.EncodeBig(big.NewInt(height))
+	proofKey := hexutil.Encode(keyBytes)
+	time1 := time.Now()
+	//2. get proof
+	proof, err := tools.GetProof(this.config.BSCConfig.URL(), this.config.BSCConfig.ECCDContractAddress, proofKey, heightHex, this.restClient)
+	if err != nil {
+		log.Errorf("handleLockDepositEvents - error :%s\n", err.Error())
+		return
+	}
+	time2 := time.Now()
+	record := bscToPolyRecord(crosstx)
+	recordStage(this.db, record, &db.LifecycleStage{Stage: stageProofFetched, Height: uint64(height)})
+	//3. commit proof to poly
+
+	txHash, err := this.commitProof(acc, uint32(height), proof, crosstx.value, crosstx.txId)
+	time3 := time.Now()
+	log.Infof("tools.GetProof took %s commitProof took %s", time2.Sub(time1).String(), time3.Sub(time2).String())
+	if err != nil {
+		if strings.Contains(err.Error(), "chooseUtxos, current utxo is not enough") {
+			log.Infof("handleLockDepositEvents - invokeNativeContract error: %s", err)
+			return
+		} else {
+			if strings.Contains(err.Error(), "tx already done") {
+				log.Debugf("handleLockDepositEvents - eth_tx %s already on poly", ethcommon.BytesToHash(crosstx.txId).String())
+				recordStage(this.db, record, &db.LifecycleStage{Stage: stagePolyImport, Sender: acc.address.ToBase58(),
+					Status: "already_done"})
+				if err := this.db.DeleteRetry(v); err != nil {
+					log.Errorf("handleLockDepositEvents - this.db.DeleteRetry error: %s", err)
 				}
-				continue
+			} else {
+				log.Errorf("handleLockDepositEvents - invokeNativeContract error for eth_tx %s: %s", ethcommon.BytesToHash(crosstx.txId).String(), err)
+				recordStage(this.db, record, &db.LifecycleStage{Stage: stagePolyImport, Sender: acc.address.ToBase58(),
+					Status: stageFailed, Detail: err.Error()})
 			}
+			return
 		}
-		//4. put to check db for checking
-		err = this.db.PutCheck(txHash, v)
-		if err != nil {
-			log.Errorf("handleLockDepositEvents - this.db.PutCheck error: %s", err)
-		}
-		err = this.db.DeleteRetry(v)
-		if err != nil {
-			log.Errorf("handleLockDepositEvents - this.db.PutCheck error: %s", err)
-		}
-		log.Infof("handleLockDepositEvents - syncProofToAlia txHash is %s", txHash)
 	}
-	return nil
+	recordStage(this.db, record, &db.LifecycleStage{Stage: stagePolyImport, TxHash: txHash, Sender: acc.address.ToBase58()})
+	//4. put to check db for checking
+	err = this.db.PutCheck(acc.address.ToBase58(), txHash, v)
+	if err != nil {
+		log.Errorf("handleLockDepositEvents - this.db.PutCheck error: %s", err)
+	}
+	err = this.db.DeleteRetry(v)
+	if err != nil {
+		log.Errorf("handleLockDepositEvents - this.db.PutCheck error: %s", err)
+	}
+	acc.logger(crosstx.txId).With(log.Fields{log.FieldPolyTx: txHash}).Infof("handleLockDepositEvents - proof is imported to poly")
 }
 
-func (this *BSCManager) commitProof(height uint32, proof []byte, value []byte, txhash []byte) (string, error) {
+func (this *BSCManager) commitProof(acc *polyAccount, height uint32, proof []byte, value []byte, txhash []byte) (string, error) {
 	log.Debugf("commit proof, height: %d, proof: %s, value: %s, txhash: %s", height, string(proof), hex.EncodeToString(value), hex.EncodeToString(txhash))
-	tx, err := this.polySdk.Native.Ccm.ImportOuterTransfer(
+	tx, err := this.polySdk.Native.Ccm.NewImportOuterTransferTransaction(
 		this.config.BSCConfig.SideChainId,
 		value,
 		height,
 		proof,
-		ethcommon.Hex2Bytes(this.polySigner.Address.ToHexString()),
-		[]byte{},
-		this.polySigner)
+		ethcommon.Hex2Bytes(acc.address.ToHexString()),
+		[]byte{})
+	if err != nil {
+		return "", err
+	}
+	hash, err := this.sendPolyTx(acc.signer, tx)
 	if err != nil {
 		return "", err
 	} else {
-		log.Infof("commitProof - send transaction to poly chain: ( poly_txhash: %s, eth_txhash: %s, height: %d )",
-			tx.ToHexString(), ethcommon.BytesToHash(txhash).String(), height)
-		return tx.ToHexString(), nil
+		acc.logger(txhash).With(log.Fields{log.FieldPolyTx: hash.ToHexString(), log.FieldHeight: height}).
+			Infof("commitProof - send transaction to poly chain")
+		return hash.ToHexString(), nil
 	}
 }
+
+// sendPolyTx signs the tx with the poly signer, local or remote, and sends it
+func (this *BSCManager) sendPolyTx(signer sdk.Signer, tx *ptypes.Transaction) (common.Uint256, error) {
+	if err := this.polySdk.SignToTransaction(tx, signer); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return this.polySdk.SendTransaction(tx)
+}
+
 func (this *BSCManager) parserValue(value []byte) []byte {
 	source := common.NewZeroCopySource(value)
 	txHash, eof := source.NextVarBytes()
@@ -537,30 +610,48 @@ func (this *BSCManager) CheckDeposit() {
 		}
 	}
 }
+
+// checkLockDepositEvents looks for the results of the poly txs of each signer,
+// the failed ones are retried by the next free proof signer
 func (this *BSCManager) checkLockDepositEvents() error {
 	checkMap, err := this.db.GetAllCheck()
 	if err != nil {
 		return fmt.Errorf("checkLockDepositEvents - this.db.GetAllCheck error: %s", err)
 	}
-	for k, v := range checkMap {
-		event, err := this.polySdk.GetSmartContractEvent(k)
-		if err != nil {
-			log.Errorf("checkLockDepositEvents - this.aliaSdk.GetSmartContractEvent error: %s", err)
-			continue
-		}
-		if event == nil {
-			continue
-		}
-		if event.State != 1 {
-			log.Infof("checkLockDepositEvents - state of poly tx %s is not success", k)
-			err := this.db.PutRetry(v)
+	for _, acc := range this.proofSigners {
+		polyProofPending.Set(float64(len(checkMap[acc.address.ToBase58()])), acc.address.ToBase58())
+	}
+	for signer, checks := range checkMap {
+		for k, v := range checks {
+			event, err := this.polySdk.GetSmartContractEvent(k)
 			if err != nil {
-				log.Errorf("checkLockDepositEvents - this.db.PutRetry error:%s", err)
+				log.Errorf("checkLockDepositEvents - this.aliaSdk.GetSmartContractEvent error: %s", err)
+				continue
+			}
+			if event == nil {
+				continue
+			}
+			if crosstx := new(CrossTransfer); crosstx.Deserialization(common.NewZeroCopySource(v)) == nil {
+				status := stageSuccess
+				if event.State != 1 {
+					status = stageFailed
+				}
+				recordStage(this.db, bscToPolyRecord(crosstx), &db.LifecycleStage{Stage: stagePolyConfirmed, TxHash: k,
+					Sender: signer, Status: status})
+			}
+			if event.State != 1 {
+				log.Module("BSCManager").With(log.Fields{log.FieldDirection: log.BSCToPoly, log.FieldSender: signer, log.FieldPolyTx: k}).
+					Infof("checkLockDepositEvents - state of poly tx is not success, retry it")
+				polyProofFailures.Inc(signer)
+				err := this.db.PutRetry(v)
+				if err != nil {
+					log.Errorf("checkLockDepositEvents - this.db.PutRetry error:%s", err)
+				}
+			}
+			err = this.db.DeleteCheck(signer, k)
+			if err != nil {
+				log.Errorf("checkLockDepositEvents - this.db.DeleteRetry error:%s", err)
 			}
-		}
-		err = this.db.DeleteCheck(k)
-		if err != nil {
-			log.Errorf("checkLockDepositEvents - this.db.DeleteRetry error:%s", err)
 		}
 	}
 	return nil
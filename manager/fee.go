@@ -0,0 +1,323 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/log"
+	"github.com/polynetwork/poly/common"
+
+	"poly_bridge_sdk"
+)
+
+const (
+	feeRelay = iota
+	feeDefer
+	feeSkip
+)
+
+// results of a relay attempt
+const (
+	relayRetry    = iota // failed, the relay is tried again
+	relayDone            // sent to the send queue, already relayed or skipped
+	relayDeferred        // gas is above MaxPrice or the fee covers part of the cost, it waits for cheaper gas in the fee queue
+)
+
+var weiPerBNB = new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+
+// parseFee reads the fee amount reported by the bridge, nil if it is unknown
+func parseFee(amount string) *big.Float {
+	fee, ok := new(big.Float).SetString(amount)
+	if !ok {
+		return nil
+	}
+	return fee
+}
+
+// checkProfit compares the fee paid with the cost of the BSC tx in wei and
+// decides whether to relay now, wait for cheaper gas or skip the relay
+func checkProfit(cfg *config.BridgeConfig, fee *big.Float, cost *big.Int) (int, string) {
+	if cfg == nil || cfg.NativeTokenPrice <= 0 || fee == nil || cost.Sign() <= 0 {
+		return feeRelay, ""
+	}
+	costValue := new(big.Float).Quo(new(big.Float).SetInt(cost), weiPerBNB)
+	costValue.Mul(costValue, big.NewFloat(cfg.NativeTokenPrice))
+	ratio, _ := new(big.Float).Quo(fee, costValue).Float64()
+
+	minRatio := cfg.MinFeeRatio
+	if minRatio <= 0 {
+		minRatio = 1
+	}
+	reason := fmt.Sprintf("fee %s covers %.2f of the tx cost %s", fee.Text('f', -1), ratio, costValue.Text('f', 8))
+	switch {
+	case ratio >= minRatio:
+		return feeRelay, reason
+	case cfg.DeferFeeRatio > 0 && ratio >= cfg.DeferFeeRatio:
+		return feeDefer, reason
+	default:
+		return feeSkip, reason
+	}
+}
+
+// FeePending is a transfer waiting in the fee queue until the bridge reports it paid.
+// Only the position of the cross chain tx on poly is kept, the proof is fetched again
+// when it gets relayed since the epoch of ECCM may have changed meanwhile.
+type FeePending struct {
+	PolyTxHash  string
+	Height      uint32 // poly height of the makeProof event
+	Key         string // key of the cross states proof
+	FromChainId uint64
+	TxHash      string // source chain tx hash asked to the bridge
+	ToContract  string
+	QueuedAt    int64
+	DeferredAt  int64 // first time the fee covered only part of the cost, 0 if never
+}
+
+func (this *FeePending) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteString(this.PolyTxHash)
+	sink.WriteUint32(this.Height)
+	sink.WriteString(this.Key)
+	sink.WriteUint64(this.FromChainId)
+	sink.WriteString(this.TxHash)
+	sink.WriteString(this.ToContract)
+	sink.WriteInt64(this.QueuedAt)
+	sink.WriteInt64(this.DeferredAt)
+}
+
+func (this *FeePending) Deserialization(source *common.ZeroCopySource) error {
+	var eof bool
+	if this.PolyTxHash, eof = source.NextString(); eof {
+		return fmt.Errorf("FeePending deserialize poly tx hash error")
+	}
+	if this.Height, eof = source.NextUint32(); eof {
+		return fmt.Errorf("FeePending deserialize height error")
+	}
+	if this.Key, eof = source.NextString(); eof {
+		return fmt.Errorf("FeePending deserialize key error")
+	}
+	if this.FromChainId, eof = source.NextUint64(); eof {
+		return fmt.Errorf("FeePending deserialize from chain id error")
+	}
+	if this.TxHash, eof = source.NextString(); eof {
+		return fmt.Errorf("FeePending deserialize tx hash error")
+	}
+	if this.ToContract, eof = source.NextString(); eof {
+		return fmt.Errorf("FeePending deserialize to contract error")
+	}
+	if this.QueuedAt, eof = source.NextInt64(); eof {
+		return fmt.Errorf("FeePending deserialize queued time error")
+	}
+	// missing in the transfers queued by older versions
+	if this.DeferredAt, eof = source.NextInt64(); eof {
+		this.DeferredAt = 0
+	}
+	return nil
+}
+
+func feeKey(chainId uint64, hash string) string {
+	return fmt.Sprintf("%d:%s", chainId, strings.ToLower(strings.TrimPrefix(hash, "0x")))
+}
+
+// checkFee asks the bridge for the fee states of the transfers in one request,
+// the states are keyed by feeKey
+func (this *PolyManager) checkFee(items []*FeePending) (map[string]*poly_bridge_sdk.CheckFeeRsp, error) {
+	reqs := make([]*poly_bridge_sdk.CheckFeeReq, len(items))
+	for i, v := range items {
+		reqs[i] = &poly_bridge_sdk.CheckFeeReq{Hash: v.TxHash, ChainId: v.FromChainId}
+	}
+	resp, err := this.bridgeSdk.CheckFee(reqs)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]*poly_bridge_sdk.CheckFeeRsp, len(resp))
+	for _, v := range resp {
+		if v != nil {
+			states[feeKey(v.ChainId, v.Hash)] = v
+		}
+	}
+	return states, nil
+}
+
+// deferFee puts the transfer into the fee queue so the poly scan keeps going
+func (this *PolyManager) deferFee(item *FeePending) {
+	item.QueuedAt = time.Now().Unix()
+	if err := putFeePending(this.db, item); err != nil {
+		log.Errorf("deferFee - failed to queue poly_hash %s: %v", item.PolyTxHash, err)
+		return
+	}
+	log.Infof("%v is not paid yet, wait in the fee queue (TxHash:%s FromChainID:%d)", item.PolyTxHash, item.TxHash, item.FromChainId)
+}
+
+// deferGas puts a paid transfer back into the fee queue while the gas price is
+// above MaxPrice or its fee covers only part of the cost, it is relayed by
+// checkFeeQueue once gas is cheaper or skipped after MaxDeferTime
+func (this *PolyManager) deferGas(item *FeePending) {
+	if item.DeferredAt == 0 {
+		item.DeferredAt = time.Now().Unix()
+	}
+	if err := putFeePending(this.db, item); err != nil {
+		log.Errorf("deferGas - failed to queue poly_hash %s: %v", item.PolyTxHash, err)
+		return
+	}
+	log.Infof("%v waits for cheaper gas in the fee queue", item.PolyTxHash)
+}
+
+// putFeePending stores the transfer in the fee queue, QueuedAt is set if it
+// was never queued
+func putFeePending(boltDB *db.BoltDB, item *FeePending) error {
+	if item.QueuedAt == 0 {
+		item.QueuedAt = time.Now().Unix()
+	}
+	sink := common.NewZeroCopySink(nil)
+	item.Serialization(sink)
+	return boltDB.PutFeePending(item.PolyTxHash, sink.Bytes())
+}
+
+// MonitorFee re-checks the fee queue in batches and relays the paid transfers
+func (this *PolyManager) MonitorFee() {
+	ticker := time.NewTicker(config.FEE_CHECK_INTERVAL)
+	for {
+		select {
+		case <-ticker.C:
+			this.checkFeeQueue()
+		case <-this.exitChan:
+			return
+		}
+	}
+}
+
+func (this *PolyManager) checkFeeQueue() {
+	raw, err := this.db.GetAllFeePending()
+	if err != nil {
+		log.Errorf("checkFeeQueue - failed to load fee queue: %v", err)
+		return
+	}
+	items := make([]*FeePending, 0, len(raw))
+	for k, v := range raw {
+		item := &FeePending{}
+		if err := item.Deserialization(common.NewZeroCopySource(v)); err != nil {
+			log.Errorf("checkFeeQueue - drop %s: %v", k, err)
+			_ = this.db.DeleteFeePending(k)
+			continue
+		}
+		items = append(items, item)
+	}
+	feeQueueSize.Set(float64(len(items)))
+	// free transfers only wait for cheaper gas here, the bridge does not know them
+	paying := items[:0]
+	for _, item := range items {
+		if !this.config.IsFree(item.ToContract) {
+			paying = append(paying, item)
+			continue
+		}
+		if this.relayPending(item, nil) {
+			_ = this.db.DeleteFeePending(item.PolyTxHash)
+		}
+	}
+	items = paying
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].QueuedAt < items[j].QueuedAt
+	})
+
+	expire := config.DEFAULT_FEE_EXPIRE_TIME
+	if this.config.BridgeConfig.FeeExpireTime > 0 {
+		expire = time.Duration(this.config.BridgeConfig.FeeExpireTime) * time.Second
+	}
+	for start := 0; start < len(items); start += config.FEE_CHECK_BATCH {
+		end := start + config.FEE_CHECK_BATCH
+		if end > len(items) {
+			end = len(items)
+		}
+		batch := items[start:end]
+		states, err := this.checkFee(batch)
+		if err != nil {
+			log.Errorf("checkFeeQueue - CheckFee of %d transfers failed: %v", len(batch), err)
+			for _, item := range batch {
+				if !this.failOpen(item) {
+					continue
+				}
+				recordStage(this.db, polyToBSCRecord(item.PolyTxHash), &db.LifecycleStage{Stage: stageFeeCheck, Status: "fail_open",
+					Detail: err.Error()})
+				if this.relayPending(item, nil) {
+					_ = this.db.DeleteFeePending(item.PolyTxHash)
+				}
+			}
+			continue
+		}
+		for _, item := range batch {
+			if rsp, ok := states[feeKey(item.FromChainId, item.TxHash)]; ok && rsp.PayState == poly_bridge_sdk.STATE_HASPAY {
+				log.Infof("%v is paid, start processing", item.PolyTxHash)
+				recordFeeCheck(this.db, item.PolyTxHash, true, parseFee(rsp.Amount))
+				if this.relayPending(item, parseFee(rsp.Amount)) {
+					_ = this.db.DeleteFeePending(item.PolyTxHash)
+				}
+				continue
+			}
+			if time.Since(time.Unix(item.QueuedAt, 0)) > expire {
+				log.Infof("%v skipped because not paid in %s", item.PolyTxHash, expire.String())
+				_ = this.db.PutSkip(item.PolyTxHash, "fee not paid in "+expire.String())
+				recordStage(this.db, polyToBSCRecord(item.PolyTxHash), &db.LifecycleStage{Stage: stageSkipped,
+					Status: stageUnpaid, Detail: "fee not paid in " + expire.String()})
+				_ = this.db.DeleteFeePending(item.PolyTxHash)
+			}
+		}
+	}
+}
+
+// relayPending fetches the header and proof of a queued transfer again and relays it,
+// false means it stays in the queue
+func (this *PolyManager) relayPending(item *FeePending, fee *big.Float) bool {
+	lastEpoch := this.findLatestHeight()
+	hdr, err := this.polySdk.GetHeaderByHeight(item.Height + 1)
+	if err != nil {
+		log.Errorf("relayPending - GetNodeHeader on height :%d failed: %v", item.Height+1, err)
+		return false
+	}
+	isEpoch, _, err := this.IsEpoch(hdr)
+	if err != nil {
+		log.Errorf("relayPending - falied to check isEpoch: %v", err)
+		return false
+	}
+	anchor, hp := this.getAnchor(item.Height, lastEpoch, isEpoch)
+	param, auditpath, err := this.getCrossStates(hdr, item.Key)
+	if err != nil {
+		log.Errorf("relayPending - %v", err)
+		return false
+	}
+	return this.relay(hdr, param, hp, anchor, item, auditpath, fee)
+}
+
+// failOpen applies FeeFailPolicy to a transfer whose fee could not be checked
+func (this *PolyManager) failOpen(item *FeePending) bool {
+	var waited time.Duration
+	if item.QueuedAt > 0 {
+		waited = time.Since(time.Unix(item.QueuedAt, 0))
+	}
+	if !this.config.BridgeConfig.FailOpen(item.FromChainId, item.ToContract, waited) {
+		return false
+	}
+	log.Warnf("%v is relayed without fee check by policy %s, fee service fails", item.PolyTxHash, this.config.BridgeConfig.FeeFailPolicy)
+	feeFailOpen.Inc(this.config.BridgeConfig.FeeFailPolicy)
+	return true
+}
@@ -0,0 +1,137 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/poly/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProfit(t *testing.T) {
+	cfg := &config.BridgeConfig{NativeTokenPrice: 300, DeferFeeRatio: 0.5}
+	// 200000 gas at 10 gwei costs 0.002 BNB, 0.6 in the fee unit
+	cost := new(big.Int).Mul(big.NewInt(200000), big.NewInt(10000000000))
+
+	decision, _ := checkProfit(cfg, parseFee("0.7"), cost)
+	assert.Equal(t, feeRelay, decision)
+	decision, _ = checkProfit(cfg, parseFee("0.4"), cost)
+	assert.Equal(t, feeDefer, decision)
+	decision, reason := checkProfit(cfg, parseFee("0.1"), cost)
+	assert.Equal(t, feeSkip, decision)
+	assert.NotEmpty(t, reason)
+
+	// unknown fee amounts and a missing price do not block relays
+	decision, _ = checkProfit(cfg, parseFee(""), cost)
+	assert.Equal(t, feeRelay, decision)
+	decision, _ = checkProfit(&config.BridgeConfig{}, parseFee("0.1"), cost)
+	assert.Equal(t, feeRelay, decision)
+}
+
+func TestFeePendingSerialization(t *testing.T) {
+	item := &FeePending{
+		PolyTxHash:  "c2b0f8f6",
+		Height:      100,
+		Key:         "0a1b",
+		FromChainId: 2,
+		TxHash:      "9d4e",
+		ToContract:  "0x2aA58b7A4D0A3EbA1Ab6Ac66A2AcD2C9E2a5c1c6",
+		QueuedAt:    1600000000,
+		DeferredAt:  1600000600,
+	}
+	sink := common.NewZeroCopySink(nil)
+	item.Serialization(sink)
+	res := &FeePending{}
+	assert.NoError(t, res.Deserialization(common.NewZeroCopySource(sink.Bytes())))
+	assert.Equal(t, item, res)
+
+	// queued by a version without DeferredAt
+	res = &FeePending{}
+	assert.NoError(t, res.Deserialization(common.NewZeroCopySource(sink.Bytes()[:len(sink.Bytes())-8])))
+	assert.Equal(t, int64(0), res.DeferredAt)
+	assert.Equal(t, item.QueuedAt, res.QueuedAt)
+
+	assert.Error(t, res.Deserialization(common.NewZeroCopySource(sink.Bytes()[:10])))
+	assert.Equal(t, feeKey(2, "9D4E"), feeKey(2, "0x9d4e"))
+}
+
+func TestFailOpenPolicy(t *testing.T) {
+	cfg := &config.BridgeConfig{}
+	assert.False(t, cfg.FailOpen(2, "0xabc", time.Hour))
+
+	cfg = &config.BridgeConfig{
+		FeeFailPolicy:     config.FEE_POLICY_FAIL_OPEN_ALLOWLIST,
+		FailOpenChains:    []uint64{6},
+		FailOpenContracts: []string{"0xAbC"},
+	}
+	assert.True(t, cfg.FailOpen(6, "0xdef", 0))
+	assert.True(t, cfg.FailOpen(2, "0xabc", 0))
+	assert.False(t, cfg.FailOpen(2, "0xdef", time.Hour))
+
+	cfg = &config.BridgeConfig{FeeFailPolicy: config.FEE_POLICY_FAIL_OPEN_TIMEOUT, FailOpenAfter: 600}
+	assert.False(t, cfg.FailOpen(2, "0xabc", time.Minute))
+	assert.True(t, cfg.FailOpen(2, "0xabc", 10*time.Minute))
+}
+
+func TestDeferGas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "defer")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	boltDB, err := db.NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer boltDB.Close()
+
+	this := &PolyManager{db: boltDB}
+	item := &FeePending{PolyTxHash: "c2b0f8f6", QueuedAt: 1600000000}
+	this.deferGas(item)
+	deferredAt := item.DeferredAt
+	assert.NotZero(t, deferredAt)
+
+	// the first defer time is kept, MaxDeferTime counts from it
+	this.deferGas(item)
+	raw, err := boltDB.GetAllFeePending()
+	if !assert.NoError(t, err) || !assert.Len(t, raw, 1) {
+		return
+	}
+	res := &FeePending{}
+	assert.NoError(t, res.Deserialization(common.NewZeroCopySource(raw["c2b0f8f6"])))
+	assert.Equal(t, deferredAt, res.DeferredAt)
+	assert.Equal(t, int64(1600000000), res.QueuedAt)
+
+	// a relay dropped by a reorg goes back without starting MaxDeferTime
+	dropped := &FeePending{PolyTxHash: "5e1d7a04"}
+	assert.NoError(t, putFeePending(boltDB, dropped))
+	raw, err = boltDB.GetAllFeePending()
+	if !assert.NoError(t, err) || !assert.Len(t, raw, 2) {
+		return
+	}
+	res = &FeePending{}
+	assert.NoError(t, res.Deserialization(common.NewZeroCopySource(raw["5e1d7a04"])))
+	assert.NotZero(t, res.QueuedAt)
+	assert.Zero(t, res.DeferredAt)
+}
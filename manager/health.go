@@ -0,0 +1,165 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/log"
+)
+
+const (
+	defaultSenderMaxFailures = 3
+	defaultSenderCooldown    = 5 * time.Minute
+	senderReportInterval     = time.Minute
+)
+
+// senderHealth counts the consecutive failures of a sender, after maxFailures
+// the sender is quarantined for cooldown. A re-admitted sender is on probation,
+// one more failure sends it back.
+type senderHealth struct {
+	addr        string
+	maxFailures int
+	cooldown    time.Duration
+
+	lock             sync.Mutex
+	failures         int
+	lastErr          string
+	lastErrAt        time.Time
+	quarantinedUntil time.Time
+}
+
+func newSenderHealth(addr string, cfg *config.BSCConfig) *senderHealth {
+	h := &senderHealth{addr: addr, maxFailures: cfg.SenderMaxFailures, cooldown: time.Duration(cfg.SenderCooldown) * time.Second}
+	if h.maxFailures <= 0 {
+		h.maxFailures = defaultSenderMaxFailures
+	}
+	if h.cooldown <= 0 {
+		h.cooldown = defaultSenderCooldown
+	}
+	senderHealthy.Set(1, addr)
+	return h
+}
+
+func (this *senderHealth) success() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.failures = 0
+	senderFailures.Set(0, this.addr)
+}
+
+func (this *senderHealth) failure(err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.failures++
+	this.lastErr = err.Error()
+	this.lastErrAt = time.Now()
+	senderFailures.Set(float64(this.failures), this.addr)
+	if this.failures >= this.maxFailures && !this.isQuarantined() {
+		this.quarantinedUntil = time.Now().Add(this.cooldown)
+		senderHealthy.Set(0, this.addr)
+		senderQuarantines.Inc(this.addr)
+		log.Errorf("sender %s is quarantined until %s after %d failures, last error: %s",
+			this.addr, this.quarantinedUntil.Format(time.RFC3339), this.failures, this.lastErr)
+	}
+}
+
+func (this *senderHealth) isQuarantined() bool {
+	return time.Now().Before(this.quarantinedUntil)
+}
+
+// available re-admits the sender once the cool-down is over
+func (this *senderHealth) available() bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.quarantinedUntil.IsZero() {
+		return true
+	}
+	if this.isQuarantined() {
+		return false
+	}
+	this.quarantinedUntil = time.Time{}
+	this.failures = this.maxFailures - 1
+	senderHealthy.Set(1, this.addr)
+	log.Infof("sender %s is re-admitted after the cool-down", this.addr)
+	return true
+}
+
+func (this *senderHealth) report(balance string, pending, queued int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	state := "healthy"
+	if this.isQuarantined() {
+		state = "quarantined until " + this.quarantinedUntil.Format(time.RFC3339)
+	}
+	if this.lastErr == "" {
+		log.Infof("sender %s is %s, balance: %s, pending: %d, queued: %d", this.addr, state, balance, pending, queued)
+		return
+	}
+	log.Infof("sender %s is %s, balance: %s, pending: %d, queued: %d, consecutive failures: %d, last error at %s: %s",
+		this.addr, state, balance, pending, queued, this.failures, this.lastErrAt.Format(time.RFC3339), this.lastErr)
+}
+
+// senderErrors are the node errors caused by the account, node outages, "nonce
+// too low" and "replacement underpriced" are not
+var senderErrors = []string{"insufficient funds", "nonce too high", "nonce has max value"}
+
+// keyError is a failure of the key of the sender, like signing with a locked account
+type keyError struct {
+	error
+}
+
+func (this keyError) Unwrap() error {
+	return this.error
+}
+
+// isSenderError tells whether an error is caused by the account itself rather
+// than by the tx being relayed or the node, only these count against its health
+func isSenderError(err error) bool {
+	if errors.As(err, &keyError{}) {
+		return true
+	}
+	for _, v := range senderErrors {
+		if strings.Contains(err.Error(), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// MonitorSenders logs the state of every sender periodically
+func (this *PolyManager) MonitorSenders() {
+	ticker := time.NewTicker(senderReportInterval)
+	for {
+		select {
+		case <-ticker.C:
+			for _, v := range this.senders {
+				balance := "unknown"
+				if bal, err := this.balances.get(v); err == nil {
+					balance = bal.String()
+				}
+				v.health.report(balance, v.tracker.Pending(), len(v.queue.ch))
+			}
+		case <-this.exitChan:
+			return
+		}
+	}
+}
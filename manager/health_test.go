@@ -0,0 +1,58 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderHealth(t *testing.T) {
+	h := newSenderHealth("0x01", &config.BSCConfig{SenderMaxFailures: 2, SenderCooldown: 60})
+	h.failure(errors.New("nonce too low"))
+	assert.True(t, h.available())
+	h.success()
+	h.failure(errors.New("nonce too low"))
+	assert.True(t, h.available())
+	h.failure(errors.New("nonce too low"))
+	assert.False(t, h.available())
+	assert.Equal(t, float64(0), senderHealthy.Get("0x01"))
+
+	// after the cool-down one more failure sends it back
+	h.quarantinedUntil = time.Now().Add(-time.Second)
+	assert.True(t, h.available())
+	assert.Equal(t, float64(1), senderHealthy.Get("0x01"))
+	h.failure(errors.New("insufficient funds for gas * price + value"))
+	assert.False(t, h.available())
+	assert.Equal(t, float64(2), senderQuarantines.Get("0x01"))
+
+	for msg, expected := range map[string]bool{
+		"insufficient funds for gas * price + value": true,
+		"nonce too high":                      true,
+		"nonce too low":                       false,
+		"replacement transaction underpriced": false,
+		"context deadline exceeded":           false,
+	} {
+		assert.Equal(t, expected, isSenderError(errors.New(msg)), msg)
+	}
+	assert.True(t, isSenderError(fmt.Errorf("relay: %w", keyError{errors.New("authentication needed: password or unlock")})))
+}
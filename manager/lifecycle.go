@@ -0,0 +1,172 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/events"
+	"github.com/polynetwork/bsc-relayer/log"
+	"github.com/polynetwork/poly/common"
+	common2 "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+// stages of bsc to poly
+const (
+	stageEventSeen     = "event_seen"
+	stageProofFetched  = "proof_fetched"
+	stagePolyImport    = "poly_import"
+	stagePolyConfirmed = "poly_confirmed"
+)
+
+// stages of poly to bsc
+const (
+	stageMakeProof  = "make_proof"
+	stageFeeCheck   = "fee_check"
+	stageBSCRelay   = "bsc_relay"
+	stageBSCReceipt = "bsc_receipt"
+	stageSkipped    = "skipped"
+)
+
+const (
+	stageSuccess  = "success"
+	stageFailed   = "failed"
+	stageUnpaid   = "unpaid"
+	stageFiltered = "filtered"
+)
+
+// bscToPolyRecord is the record of a cross chain event of the bsc tx, a bsc tx
+// may have more than one
+func bscToPolyRecord(crosstx *CrossTransfer) *db.Lifecycle {
+	bscTx := hex.EncodeToString(crosstx.txId)
+	ccid := ""
+	param := &common2.MakeTxParam{}
+	if err := param.Deserialization(common.NewZeroCopySource(crosstx.value)); err == nil {
+		ccid = hex.EncodeToString(param.CrossChainID)
+	}
+	return &db.Lifecycle{
+		ID:        log.BSCToPoly + "/" + bscTx + "/" + ccid,
+		Direction: log.BSCToPoly,
+		CCID:      ccid,
+		SourceTx:  bscTx,
+	}
+}
+
+// polyToBSCRecord is the record of the poly tx, which has one makeProof
+func polyToBSCRecord(polyTxHash string) *db.Lifecycle {
+	return &db.Lifecycle{
+		ID:        log.PolyToBSC + "/" + db.NormalizeKey(polyTxHash),
+		Direction: log.PolyToBSC,
+		SourceTx:  polyTxHash,
+	}
+}
+
+// recordFeeCheck records the result of the fee check, the fee is nil if unknown
+func recordFeeCheck(boltDB *db.BoltDB, polyTxHash string, paid bool, fee *big.Float) {
+	stage := &db.LifecycleStage{Stage: stageFeeCheck, Status: "unpaid"}
+	if paid {
+		stage.Status = "paid"
+	}
+	if fee != nil {
+		stage.Detail = "fee " + fee.String()
+	}
+	recordStage(boltDB, polyToBSCRecord(polyTxHash), stage)
+}
+
+// recordStage adds the stage to the lifecycle index and publishes its event in
+// the same DB transaction, a failure is only logged
+func recordStage(boltDB *db.BoltDB, record *db.Lifecycle, stage *db.LifecycleStage, keys ...string) {
+	if boltDB == nil {
+		return
+	}
+	var event func(saved *db.Lifecycle) ([]byte, error)
+	if typ := eventType(stage); typ != "" && events.Enabled() {
+		event = func(saved *db.Lifecycle) ([]byte, error) {
+			// a repeated stage, like a failing import, is published once
+			if stage.Count != 0 {
+				return nil, nil
+			}
+			return events.Encode(&events.Event{
+				Type:      typ,
+				Stage:     stage.Stage,
+				Direction: saved.Direction,
+				Time:      stage.Time,
+				SourceTx:  saved.SourceTx,
+				CCID:      saved.CCID,
+				TxHash:    stage.TxHash,
+				Height:    stage.Height,
+				Sender:    stage.Sender,
+				Nonce:     stage.Nonce,
+				Detail:    stage.Detail,
+			})
+		}
+	}
+	if _, err := boltDB.AddLifecycleStageWithEvent(record, stage, event, keys...); err != nil {
+		log.Errorf("recordStage - failed to record %s of %s: %v", stage.Stage, record.ID, err)
+		return
+	}
+	if event != nil {
+		events.Notify()
+	}
+}
+
+// recordFiltered publishes the event of a tx not relayed by the TargetContracts
+// or WhitelistMethods, it is not indexed
+func recordFiltered(direction, sourceTx, ccid string, height uint64, reason string) {
+	events.Publish(&events.Event{
+		Type:      events.SkippedFiltered,
+		Stage:     stageSkipped,
+		Direction: direction,
+		SourceTx:  db.NormalizeKey(sourceTx),
+		CCID:      ccid,
+		Height:    height,
+		Detail:    reason,
+	})
+}
+
+// eventType is the event of the stage, none for the stages in between
+func eventType(stage *db.LifecycleStage) string {
+	switch stage.Stage {
+	case stageEventSeen, stageMakeProof:
+		return events.Seen
+	case stagePolyImport, stageBSCRelay:
+		switch stage.Status {
+		case "":
+			return events.Submitted
+		case stageFailed:
+			return events.Failed
+		}
+	case stagePolyConfirmed, stageBSCReceipt:
+		switch stage.Status {
+		case stageSuccess:
+			return events.Confirmed
+		case stageFailed:
+			return events.Failed
+		}
+	case stageSkipped:
+		switch stage.Status {
+		case stageUnpaid:
+			return events.SkippedUnpaid
+		case stageFiltered:
+			return events.SkippedFiltered
+		}
+		return events.Failed
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventType(t *testing.T) {
+	cases := []struct {
+		stage, status, typ string
+	}{
+		{stageEventSeen, "", events.Seen},
+		{stageProofFetched, "", ""},
+		{stagePolyImport, "", events.Submitted},
+		{stagePolyImport, stageFailed, events.Failed},
+		{stagePolyImport, "already_done", ""},
+		{stagePolyConfirmed, stageSuccess, events.Confirmed},
+		{stageMakeProof, "", events.Seen},
+		{stageFeeCheck, "unpaid", ""},
+		{stageBSCRelay, "", events.Submitted},
+		{stageBSCReceipt, "dropped", ""},
+		{stageBSCReceipt, stageFailed, events.Failed},
+		{stageSkipped, stageUnpaid, events.SkippedUnpaid},
+		{stageSkipped, stageFailed, events.Failed},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.typ, eventType(&db.LifecycleStage{Stage: c.stage, Status: c.status}), c.stage+" "+c.status)
+	}
+}
+
+func TestSkipRelay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skip")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	boltDB, err := db.NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer boltDB.Close()
+
+	sender := &EthSender{db: boltDB}
+	sender.skipRelay("0xAB", stageUnpaid, "fee too low: 1 < 2")
+	reason, _ := boltDB.GetSkip("0xAB")
+	assert.Equal(t, "fee too low: 1 < 2", reason)
+	records, err := boltDB.GetLifecycles("ab")
+	if assert.NoError(t, err) && assert.Len(t, records, 1) {
+		stages := records[0].Stages
+		assert.Equal(t, stageSkipped, stages[len(stages)-1].Stage)
+		assert.Equal(t, stageUnpaid, stages[len(stages)-1].Status)
+	}
+}
+
+func TestRecordStageEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	boltDB, err := db.NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer boltDB.Close()
+	publisher, err := events.NewPublisher(boltDB)
+	if !assert.NoError(t, err) {
+		return
+	}
+	events.SetDefault(publisher)
+	defer events.SetDefault(nil)
+
+	// the event is put with the stage, a repeated stage puts none
+	record := polyToBSCRecord("0xCD")
+	failed := &db.LifecycleStage{Stage: stageBSCRelay, Status: stageFailed, Detail: "busy"}
+	recordStage(boltDB, record, failed)
+	recordStage(boltDB, record, &db.LifecycleStage{Stage: stageBSCRelay, Status: stageFailed, Detail: "busy"})
+	recordStage(boltDB, record, &db.LifecycleStage{Stage: stageFeeCheck, Status: "paid"})
+	ids, raws, err := boltDB.GetEvents(0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, ids, 1) {
+		evt := &events.Event{}
+		assert.NoError(t, json.Unmarshal(raws[0], evt))
+		assert.Equal(t, events.Failed, evt.Type)
+		assert.Equal(t, "cd", evt.SourceTx)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"math/big"
+
+	"github.com/polynetwork/bsc-relayer/metrics"
+)
+
+var (
+	gasLimitRefused = metrics.NewCounterVec("relayer_gas_limit_refused_total",
+		"Relays refused because the estimated gas limit is above the ceiling", "contract")
+	feeTooLow = metrics.NewCounterVec("relayer_fee_too_low_total",
+		"Relays skipped because the fee paid does not cover the BSC tx cost")
+	feeQueueSize = metrics.NewGaugeVec("relayer_fee_queue_size",
+		"Transfers waiting in the fee queue")
+	feeFailOpen = metrics.NewCounterVec("relayer_fee_fail_open_total",
+		"Transfers relayed without fee check while the fee service fails", "policy")
+
+	senderHealthy = metrics.NewGaugeVec("relayer_sender_healthy",
+		"1 if the sender can be selected, 0 while it is quarantined", "sender")
+	senderFailures = metrics.NewGaugeVec("relayer_sender_consecutive_failures",
+		"Consecutive failures of the sender", "sender")
+	senderQuarantines = metrics.NewCounterVec("relayer_sender_quarantines_total",
+		"Times the sender was quarantined", "sender")
+	senderBalance = metrics.NewGaugeVec("relayer_sender_balance_bnb",
+		"Last known balance of the sender", "sender")
+	senderPending = metrics.NewGaugeVec("relayer_sender_pending_txs",
+		"Unmined transactions of the sender", "sender")
+	sendQueueDepth = metrics.NewGaugeVec("relayer_sender_queue_depth",
+		"Relays queued for the sender and not confirmed yet", "sender")
+	senderLowBalance = metrics.NewGaugeVec("relayer_sender_low_balance",
+		"1 if the balance of the sender is below the alert balance", "sender")
+	topUpTotal = metrics.NewCounterVec("relayer_top_up_bnb_total",
+		"BNB sent by the treasury to the sender", "sender")
+	polyRelayerApproved = metrics.NewGaugeVec("relayer_poly_relayer_approved",
+		"1 if the poly signer is an approved relayer of poly", "address")
+	polyProofPending = metrics.NewGaugeVec("relayer_poly_proof_pending",
+		"Proofs imported by the poly signer and not checked yet", "signer")
+	polyProofFailures = metrics.NewCounterVec("relayer_poly_proof_failures_total",
+		"Proofs imported by the poly signer which failed on poly and are retried", "signer")
+	topUpFailures = metrics.NewCounterVec("relayer_top_up_failures_total",
+		"Top-ups of the sender which failed or were refused by the daily cap", "sender")
+)
+
+func weiToBNB(wei *big.Int) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), weiPerBNB).Float64()
+	return f
+}
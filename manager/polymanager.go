@@ -21,11 +21,10 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
-	"os"
-	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -57,9 +56,7 @@ import (
 	polytypes "github.com/polynetwork/poly/core/types"
 )
 
-const (
-	ChanLen = 1
-)
+var errTxDropped = errors.New("transaction is dropped from the canonical chain")
 
 type PolyManager struct {
 	config       *config.ServiceConfig
@@ -72,6 +69,10 @@ type PolyManager struct {
 	bridgeSdk    *poly_bridge_sdk.BridgeFeeCheck
 	senders      []*EthSender
 	eccdInstance *eccd_abi.EthCrossChainData
+	relayLock    sync.Mutex
+	selector     SenderSelector
+	balances     *balanceCache
+	treasury     *treasury
 }
 
 func NewPolyManager(servCfg *config.ServiceConfig, startblockHeight uint32, polySdk *sdk.PolySdk, ethereumsdk *ethclient.Client, bridgeSdk *poly_bridge_sdk.BridgeFeeCheck, boltDB *db.BoltDB) (*PolyManager, error) {
@@ -83,21 +84,18 @@ func NewPolyManager(servCfg *config.ServiceConfig, startblockHeight uint32, poly
 	if err != nil {
 		return nil, err
 	}
-	ks := tools.NewEthKeyStore(servCfg.BSCConfig, chainId)
+	ks, err := newSigner(servCfg.BSCConfig, chainId)
+	if err != nil {
+		return nil, err
+	}
 	accArr := ks.GetAccounts()
-	if len(servCfg.BSCConfig.KeyStorePwdSet) == 0 {
-		fmt.Println("please input the passwords for ethereum keystore: ")
-		for _, v := range accArr {
-			fmt.Printf("For address %s. ", v.Address.String())
-			raw, err := password.GetPassword()
-			if err != nil {
-				log.Fatalf("failed to input password: %v", err)
-				panic(err)
-			}
-			servCfg.BSCConfig.KeyStorePwdSet[strings.ToLower(v.Address.String())] = string(raw)
-		}
+
+	gasOracle, err := tools.NewGasOracle(servCfg.BSCConfig.GasPrice, ethereumsdk, servCfg.BSCConfig.URL)
+	if err != nil {
+		return nil, err
 	}
-	if err = ks.UnlockKeys(servCfg.BSCConfig); err != nil {
+	selector, err := NewSenderSelector(servCfg.BSCConfig.SenderSelector)
+	if err != nil {
 		return nil, err
 	}
 
@@ -107,14 +105,31 @@ func NewPolyManager(servCfg *config.ServiceConfig, startblockHeight uint32, poly
 		v.acc = accArr[i]
 
 		v.ethClient = ethereumsdk
+		v.restClient = tools.NewRestClient()
+		v.db = boltDB
 		v.keyStore = ks
 		v.config = servCfg
 		v.polySdk = polySdk
 		v.contractAbi = &contractabi
-		v.nonceManager = tools.NewNonceManager(ethereumsdk)
-		v.cmap = make(map[string]chan *EthTxInfo)
+		v.nonceManager = tools.NewNonceManager(ethereumsdk, boltDB)
+		v.gasOracle = gasOracle
+		v.health = newSenderHealth(v.acc.Address.Hex(), servCfg.BSCConfig)
+		v.queue = newSendQueue(v.acc.Address.Hex(), servCfg.BSCConfig.SendQueueSize, int(servCfg.RoutineNum))
+		v.tracker, err = tools.NewTxTracker(v.acc, ks, ethereumsdk, v.nonceManager, gasOracle, boltDB, servCfg.BSCConfig.TxTracker)
+		if err != nil {
+			return nil, err
+		}
 
 		senders[i] = v
+		go v.tracker.Monitor()
+		go v.fillNonceGaps()
+		go v.dispatch()
+	}
+	var treasury *treasury
+	if servCfg.BSCConfig.TopUp.Enabled() {
+		if treasury, err = newTreasury(servCfg.BSCConfig.TopUp, chainId, senders, ethereumsdk, gasOracle, boltDB); err != nil {
+			return nil, err
+		}
 	}
 	return &PolyManager{
 		exitChan:     make(chan int),
@@ -126,9 +141,44 @@ func NewPolyManager(servCfg *config.ServiceConfig, startblockHeight uint32, poly
 		db:           boltDB,
 		ethClient:    ethereumsdk,
 		senders:      senders,
+		selector:     selector,
+		balances: newBalanceCache(time.Duration(servCfg.BSCConfig.BalanceCacheTime)*time.Second, func(sender *EthSender) (*big.Int, error) {
+			return sender.Balance()
+		}),
+		treasury: treasury,
 	}, nil
 }
 
+// newSigner is the remote signer if configured, otherwise the keystore
+// unlocked with the passwords from config or typed in
+func newSigner(cfg *config.BSCConfig, chainId *big.Int) (tools.Signer, error) {
+	if cfg.RemoteSigner != nil && cfg.RemoteSigner.URL != "" {
+		signer, err := tools.NewRemoteSigner(cfg.RemoteSigner, chainId)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("relayer accounts are signed by %s", cfg.RemoteSigner.URL)
+		return signer, nil
+	}
+	ks := tools.NewEthKeyStore(cfg, chainId)
+	if len(cfg.KeyStorePwdSet) == 0 {
+		fmt.Println("please input the passwords for ethereum keystore: ")
+		for _, v := range ks.GetAccounts() {
+			fmt.Printf("For address %s. ", v.Address.String())
+			raw, err := password.GetPassword()
+			if err != nil {
+				log.Fatalf("failed to input password: %v", err)
+				panic(err)
+			}
+			cfg.KeyStorePwdSet[strings.ToLower(v.Address.String())] = string(raw)
+		}
+	}
+	if err := ks.UnlockKeys(cfg); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
 func (this *PolyManager) findLatestHeight() uint32 {
 	if this.eccdInstance == nil {
 		address := ethcommon.HexToAddress(this.config.BSCConfig.ECCDContractAddress)
@@ -248,37 +298,30 @@ func (this *PolyManager) IsEpoch(hdr *polytypes.Header) (bool, []byte, error) {
 	return true, publickeys, nil
 }
 
-func (this *PolyManager) isPaid(param *common2.ToMerkleValue) bool {
-	if this.config.Free {
-		return true
+// isPaid asks the bridge once, transfers not known to be paid go to the fee queue.
+// The fee amount reported by the bridge is returned, nil if it is unknown.
+func (this *PolyManager) isPaid(item *FeePending) (bool, *big.Float) {
+	if this.config.IsFree(item.ToContract) {
+		return true, nil
 	}
-	for {
-		txHash := hex.EncodeToString(param.MakeTxParam.TxHash)
-		req := &poly_bridge_sdk.CheckFeeReq{Hash: txHash, ChainId: param.FromChainID}
-		resp, err := this.bridgeSdk.CheckFee([]*poly_bridge_sdk.CheckFeeReq{req})
-		if err != nil {
-			log.Errorf("CheckFee failed:%v, TxHash:%s FromChainID:%d", err, txHash, param.FromChainID)
-			time.Sleep(time.Second)
-			continue
-		}
-		if len(resp) != 1 {
-			log.Errorf("CheckFee resp invalid, length %d, TxHash:%s FromChainID:%d", len(resp), txHash, param.FromChainID)
-			time.Sleep(time.Second)
-			continue
-		}
-
-		switch resp[0].PayState {
-		case poly_bridge_sdk.STATE_HASPAY:
-			return true
-		case poly_bridge_sdk.STATE_NOTPAY:
-			return false
-		case poly_bridge_sdk.STATE_NOTCHECK:
-			log.Errorf("CheckFee STATE_NOTCHECK, TxHash:%s FromChainID:%d Poly Hash:%s, wait...", txHash, param.FromChainID, hex.EncodeToString(param.TxHash))
-			time.Sleep(time.Second)
-			continue
-		}
-
+	states, err := this.checkFee([]*FeePending{item})
+	if err != nil {
+		log.Errorf("CheckFee failed:%v, TxHash:%s FromChainID:%d", err, item.TxHash, item.FromChainId)
+		return this.failOpen(item), nil
+	}
+	rsp, ok := states[feeKey(item.FromChainId, item.TxHash)]
+	if !ok {
+		log.Errorf("CheckFee resp invalid, no state of TxHash:%s FromChainID:%d", item.TxHash, item.FromChainId)
+		return false, nil
+	}
+	if rsp.PayState != poly_bridge_sdk.STATE_HASPAY {
+		return false, nil
 	}
+	fee := parseFee(rsp.Amount)
+	if fee == nil {
+		log.Warnf("CheckFee invalid fee amount %q, TxHash:%s FromChainID:%d", rsp.Amount, item.TxHash, item.FromChainId)
+	}
+	return true, fee
 }
 
 func (this *PolyManager) handleDepositEvents(height uint32) bool {
@@ -294,19 +337,7 @@ func (this *PolyManager) handleDepositEvents(height uint32) bool {
 		log.Errorf("falied to check isEpoch: %v", err)
 		return false
 	}
-	var (
-		anchor *polytypes.Header
-		hp     string
-	)
-	if !isCurr {
-		anchor, _ = this.polySdk.GetHeaderByHeight(lastEpoch + 1)
-		proof, _ := this.polySdk.GetMerkleProof(height+1, lastEpoch+1)
-		hp = proof.AuditPath
-	} else if isEpoch {
-		anchor, _ = this.polySdk.GetHeaderByHeight(height + 2)
-		proof, _ := this.polySdk.GetMerkleProof(height+1, height+2)
-		hp = proof.AuditPath
-	}
+	anchor, hp := this.getAnchor(height, lastEpoch, isEpoch)
 
 	cnt := 0
 	events, err := this.polySdk.GetSmartContractEventByBlock(height)
@@ -325,76 +356,44 @@ func (this *PolyManager) handleDepositEvents(height uint32) bool {
 				if uint64(states[2].(float64)) != this.config.BSCConfig.SideChainId {
 					continue
 				}
-				proof, err := this.polySdk.GetCrossStatesProof(hdr.Height-1, states[5].(string))
+				param, auditpath, err := this.getCrossStates(hdr, states[5].(string))
 				if err != nil {
-					log.Errorf("handleDepositEvents - failed to get proof for key %s: %v", states[5].(string), err)
-					continue
-				}
-				auditpath, _ := hex.DecodeString(proof.AuditPath)
-				value, _, _, _ := tools.ParseAuditpath(auditpath)
-				param := &common2.ToMerkleValue{}
-				if err := param.Deserialization(common.NewZeroCopySource(value)); err != nil {
-					log.Errorf("handleDepositEvents - failed to deserialize MakeTxParam (value: %x, err: %v)", value, err)
+					log.Errorf("handleDepositEvents - %v", err)
 					continue
 				}
 
 				if !this.config.IsWhitelistMethod(param.MakeTxParam.Method) {
 					log.Errorf("Invalid target contract method %s", param.MakeTxParam.Method)
+					recordFiltered(log.PolyToBSC, event.TxHash, hex.EncodeToString(param.MakeTxParam.CrossChainID), uint64(height),
+						"method "+param.MakeTxParam.Method+" is not in WhitelistMethods")
 					continue
 				}
-				if !this.isPaid(param) {
-					log.Infof("%v skipped because not paid", event.TxHash)
+				if !this.isTarget(param) {
+					recordFiltered(log.PolyToBSC, event.TxHash, hex.EncodeToString(param.MakeTxParam.CrossChainID), uint64(height),
+						"not a TargetContracts transfer")
 					continue
 				}
-				log.Infof("%v is paid, start processing", event.TxHash)
-				var isTarget bool
-				if len(this.config.TargetContracts) > 0 {
-					toContractStr := ethcommon.BytesToAddress(param.MakeTxParam.ToContractAddress).String()
-					for _, v := range this.config.TargetContracts {
-						toChainIdArr, ok := v[toContractStr]
-						if ok {
-							if len(toChainIdArr["inbound"]) == 0 {
-								isTarget = true
-								break
-							}
-							for _, id := range toChainIdArr["inbound"] {
-								if id == param.FromChainID {
-									isTarget = true
-									break
-								}
-							}
-							if isTarget {
-								break
-							}
-						}
-					}
-					if !isTarget {
-						continue
-					}
+				record := polyToBSCRecord(event.TxHash)
+				record.CCID = hex.EncodeToString(param.MakeTxParam.CrossChainID)
+				recordStage(this.db, record, &db.LifecycleStage{Stage: stageMakeProof, Height: uint64(height), TxHash: event.TxHash},
+					hex.EncodeToString(param.MakeTxParam.TxHash))
+				item := &FeePending{
+					PolyTxHash:  event.TxHash,
+					Height:      height,
+					Key:         states[5].(string),
+					FromChainId: param.FromChainID,
+					TxHash:      hex.EncodeToString(param.MakeTxParam.TxHash),
+					ToContract:  ethcommon.BytesToAddress(param.MakeTxParam.ToContractAddress).String(),
 				}
-				cnt++
-				sender := this.selectSender()
-				log.Infof("sender %s is handling poly tx ( hash: %s, height: %d )",
-					sender.acc.Address.String(), event.TxHash, height)
-				// temporarily ignore the error for tx
-				errCount := 0
-				for {
-					if sender.commitDepositEventsWithHeader(hdr, param, hp, anchor, event.TxHash, auditpath) {
-						break
-					} else {
-						errCount++
-						if errCount > 10 {
-							log.Errorf("commitDepositEventsWithHeader %s failed too many times, skip", event.TxHash)
-							break
-						}
-						log.Errorf("commitDepositEventsWithHeader failed, retry after 1 second")
-						time.Sleep(time.Second)
-					}
+				paid, fee := this.isPaid(item)
+				recordFeeCheck(this.db, item.PolyTxHash, paid, fee)
+				if !paid {
+					this.deferFee(item)
+					continue
 				}
-
-				//if !sender.commitDepositEventsWithHeader(hdr, param, hp, anchor, event.TxHash, auditpath) {
-				//	return false
-				//}
+				log.Infof("%v is paid, start processing", event.TxHash)
+				cnt++
+				this.relay(hdr, param, hp, anchor, item, auditpath, fee)
 			}
 		}
 	}
@@ -406,28 +405,127 @@ func (this *PolyManager) handleDepositEvents(height uint32) bool {
 	return true
 }
 
-func (this *PolyManager) selectSender() *EthSender {
-	sum := big.NewInt(0)
-	balArr := make([]*big.Int, len(this.senders))
-	for i, v := range this.senders {
-	RETRY:
-		bal, err := v.Balance()
-		if err != nil {
-			log.Errorf("failed to get balance for %s: %v", v.acc.Address.String(), err)
-			time.Sleep(time.Second)
-			goto RETRY
+// getAnchor returns the anchor header and the header proof needed when the header
+// of height+1 is not signed by the current epoch of ECCM or changes the epoch
+func (this *PolyManager) getAnchor(height, lastEpoch uint32, isEpoch bool) (anchor *polytypes.Header, hp string) {
+	if lastEpoch > height {
+		anchor, _ = this.polySdk.GetHeaderByHeight(lastEpoch + 1)
+		proof, _ := this.polySdk.GetMerkleProof(height+1, lastEpoch+1)
+		hp = proof.AuditPath
+	} else if isEpoch {
+		anchor, _ = this.polySdk.GetHeaderByHeight(height + 2)
+		proof, _ := this.polySdk.GetMerkleProof(height+1, height+2)
+		hp = proof.AuditPath
+	}
+	return
+}
+
+// getCrossStates fetches the cross chain tx stored under key with its proof against hdr
+func (this *PolyManager) getCrossStates(hdr *polytypes.Header, key string) (*common2.ToMerkleValue, []byte, error) {
+	proof, err := this.polySdk.GetCrossStatesProof(hdr.Height-1, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get proof for key %s: %v", key, err)
+	}
+	auditpath, _ := hex.DecodeString(proof.AuditPath)
+	value, _, _, _ := tools.ParseAuditpath(auditpath)
+	param := &common2.ToMerkleValue{}
+	if err := param.Deserialization(common.NewZeroCopySource(value)); err != nil {
+		return nil, nil, fmt.Errorf("failed to deserialize MakeTxParam (value: %x, err: %v)", value, err)
+	}
+	return param, auditpath, nil
+}
+
+func (this *PolyManager) isTarget(param *common2.ToMerkleValue) bool {
+	if len(this.config.TargetContracts) == 0 {
+		return true
+	}
+	toContractStr := ethcommon.BytesToAddress(param.MakeTxParam.ToContractAddress).String()
+	for _, v := range this.config.TargetContracts {
+		toChainIdArr, ok := v[toContractStr]
+		if ok {
+			if toChainIdArr == nil || len(toChainIdArr.Inbound) == 0 {
+				return true
+			}
+			for _, id := range toChainIdArr.Inbound {
+				if id == param.FromChainID {
+					return true
+				}
+			}
 		}
-		sum.Add(sum, bal)
-		balArr[i] = big.NewInt(sum.Int64())
 	}
-	sum.Rand(rand.New(rand.NewSource(time.Now().Unix())), sum)
-	for i, v := range balArr {
-		res := v.Cmp(sum)
-		if res == 1 || res == 0 {
-			return this.senders[i]
+	return false
+}
+
+// relay commits the tx to BSC, the scan loop and the fee queue select a sender
+// one at a time, the simulation and the gas estimation run outside relayLock.
+// It returns false if the transfer was put back into the fee queue to wait for cheaper gas.
+func (this *PolyManager) relay(hdr *polytypes.Header, param *common2.ToMerkleValue, hp string, anchor *polytypes.Header, item *FeePending, auditpath []byte, fee *big.Float) bool {
+	polyTxHash := item.PolyTxHash
+	this.relayLock.Lock()
+	sender := this.selectSender()
+	this.relayLock.Unlock()
+	sender.logger(polyTxHash).With(log.Fields{log.FieldHeight: hdr.Height - 1}).Infof("relay - sender is handling poly tx")
+	// temporarily ignore the error for tx
+	errCount := 0
+	for {
+		switch sender.commitDepositEventsWithHeader(hdr, param, hp, anchor, item, auditpath, fee) {
+		case relayDone:
+			return true
+		case relayDeferred:
+			this.deferGas(item)
+			return false
+		}
+		errCount++
+		if errCount > 10 {
+			log.Errorf("commitDepositEventsWithHeader %s failed too many times, skip", polyTxHash)
+			sender.skipRelay(polyTxHash, stageFailed, "failed too many times")
+			return true
 		}
+		log.Errorf("commitDepositEventsWithHeader failed, retry after 1 second")
+		time.Sleep(time.Second)
+	}
+}
+
+// selectSender blocks until a sender with a known balance above MinSenderBalance
+// and room in its queue is available, which holds the poly scan back while all
+// senders are saturated
+func (this *PolyManager) selectSender() *EthSender {
+	minBalance := config.Wei(this.config.BSCConfig.MinSenderBalance)
+	for {
+		states := make([]*SenderState, 0, len(this.senders))
+		saturated := 0
+		for _, v := range this.senders {
+			if !v.health.available() {
+				continue
+			}
+			if v.queue.full() {
+				saturated++
+				continue
+			}
+			bal, err := this.balances.get(v)
+			if err != nil {
+				log.Errorf("failed to get balance for %s: %v", v.acc.Address.String(), err)
+				continue
+			}
+			senderBalance.Set(weiToBNB(bal), v.acc.Address.Hex())
+			if bal.Cmp(minBalance) < 0 {
+				log.Warnf("selectSender - balance %s of %s is below the min sender balance", bal.String(), v.acc.Address.String())
+				continue
+			}
+			pending := v.tracker.Pending()
+			senderPending.Set(float64(pending), v.acc.Address.Hex())
+			states = append(states, &SenderState{Sender: v, Balance: bal, Pending: pending + len(v.queue.ch)})
+		}
+		if len(states) > 0 {
+			return this.selector.Select(states)
+		}
+		if saturated > 0 {
+			log.Warnf("selectSender - %d senders are saturated, wait for their queues", saturated)
+		} else {
+			log.Errorf("selectSender - no sender available, retry after 1 second")
+		}
+		time.Sleep(time.Second)
 	}
-	return this.senders[0]
 }
 
 func (this *PolyManager) Stop() {
@@ -438,72 +536,135 @@ func (this *PolyManager) Stop() {
 
 type EthSender struct {
 	acc          accounts.Account
-	keyStore     *tools.EthKeyStore
-	cmap         map[string]chan *EthTxInfo
+	keyStore     tools.Signer
+	queue        *sendQueue
 	nonceManager *tools.NonceManager
+	tracker      *tools.TxTracker
+	gasOracle    *tools.BoundedGasOracle
 	ethClient    *ethclient.Client
+	restClient   *tools.RestClient
+	db           *db.BoltDB
+	health       *senderHealth
 	polySdk      *sdk.PolySdk
 	config       *config.ServiceConfig
 	contractAbi  *abi.ABI
 }
 
-func (this *EthSender) sendTxToEth(info *EthTxInfo) error {
-	nonce := this.nonceManager.GetAddressNonce(this.acc.Address)
-	origin := big.NewInt(0).Quo(big.NewInt(0).Mul(info.gasPrice, big.NewInt(12)), big.NewInt(10))
-	info.gasPrice = big.NewInt(origin.Int64())
+// logger logs the relaying of the poly tx by the sender
+func (this *EthSender) logger(polyTxHash string) *log.Entry {
+	fields := log.Fields{log.FieldDirection: log.PolyToBSC, log.FieldSender: this.acc.Address.Hex()}
+	if polyTxHash != "" {
+		fields[log.FieldPolyTx] = polyTxHash
+	}
+	return log.Module("EthSender").With(fields)
+}
+
+// sendTxToEth takes the next nonce and sends the relay, it is called by the
+// dispatcher of the sender only so nonces follow the order of the queue. The
+// returned tracked tx is waited for by confirmTx.
+func (this *EthSender) sendTxToEth(info *EthTxInfo) (*tools.TrackedTx, error) {
+	nonce, err := this.nonceManager.GetAddressNonce(this.acc.Address)
+	if err != nil {
+		return nil, fmt.Errorf("commitDepositEventsWithHeader - get nonce error: %v", err)
+	}
+	origin := big.NewInt(0).Set(info.gasPrice)
+	info.originPrice = origin
 	maxPrice := big.NewInt(0).Quo(big.NewInt(0).Mul(origin, big.NewInt(15)), big.NewInt(10))
+	if limit := this.gasOracle.MaxPrice(); limit != nil && maxPrice.Cmp(limit) > 0 {
+		maxPrice.Set(limit)
+	}
 RETRY:
 	tx := types.NewTransaction(nonce, info.contractAddr, big.NewInt(0), info.gasLimit, info.gasPrice, info.txData)
 	signedtx, err := this.keyStore.SignTransaction(tx, this.acc)
 	if err != nil {
 		this.nonceManager.ReturnNonce(this.acc.Address, nonce)
-		return fmt.Errorf("commitDepositEventsWithHeader - sign raw tx error and return nonce %d: %v", nonce, err)
+		return nil, keyError{fmt.Errorf("commitDepositEventsWithHeader - sign raw tx error and return nonce %d: %v", nonce, err)}
 	}
 
-	var (
-		hash      ethcommon.Hash
-		isSuccess bool
-	)
-	for {
-		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*20)
-		defer cancelFunc()
-		log.Infof("account %s is relaying poly_hash %s", this.acc.Address.Hex(), info.polyTxHash)
-		err = this.ethClient.SendTransaction(ctx, signedtx)
-
-		if err != nil {
-			log.Errorf("poly to bsc SendTransaction error: %v, nonce %d, account %s", err, nonce, this.acc.Address.Hex())
-			if strings.Contains(err.Error(), "transaction underpriced") {
-				goto FAIL
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*20)
+	logger := this.logger(info.polyTxHash).With(log.Fields{log.FieldBSCTx: signedtx.Hash().Hex(), log.FieldNonce: nonce})
+	logger.Infof("sendTxToEth - relaying poly tx")
+	err = this.ethClient.SendTransaction(ctx, signedtx)
+	cancelFunc()
+	if err != nil {
+		logger.Errorf("sendTxToEth - poly to bsc SendTransaction error: %v", err)
+		if strings.Contains(err.Error(), "transaction underpriced") && info.gasPrice.Cmp(maxPrice) < 0 {
+			info.gasPrice = big.NewInt(0).Quo(big.NewInt(0).Mul(info.gasPrice, big.NewInt(11)), big.NewInt(10))
+			if info.gasPrice.Cmp(maxPrice) > 0 {
+				info.gasPrice.Set(maxPrice)
 			}
-			os.Exit(1)
+			goto RETRY
 		}
-		hash = signedtx.Hash()
-
-		log.Infof("account %s is waiting poly_hash %s", this.acc.Address.Hex(), info.polyTxHash)
-		isSuccess = this.waitTransactionConfirm(info.polyTxHash, hash)
-		if isSuccess {
-			log.Infof("successful to relay tx to ethereum: (eth_hash: %s, nonce: %d, poly_hash: %s, current_price:%d, eth_explorer: %s)",
-				hash.String(), nonce, info.polyTxHash, info.gasPrice.Int64(), tools.GetExplorerUrl(this.keyStore.GetChainId())+hash.String())
-			return nil
+		if strings.Contains(err.Error(), "nonce too low") {
+			if err = this.nonceManager.SyncAddressNonce(this.acc.Address); err != nil {
+				return nil, fmt.Errorf("commitDepositEventsWithHeader - sync nonce error: %v", err)
+			}
+			if nonce, err = this.nonceManager.GetAddressNonce(this.acc.Address); err != nil {
+				return nil, fmt.Errorf("commitDepositEventsWithHeader - get nonce error: %v", err)
+			}
+			goto RETRY
 		}
+		this.nonceManager.ReturnNonce(this.acc.Address, nonce)
+		recordStage(this.db, polyToBSCRecord(info.polyTxHash), &db.LifecycleStage{Stage: stageBSCRelay, TxHash: signedtx.Hash().Hex(),
+			Sender: this.acc.Address.Hex(), Status: stageFailed, Detail: err.Error()})
+		return nil, fmt.Errorf("commitDepositEventsWithHeader - send tx error and return nonce %d: %v", nonce, err)
+	}
+	recordStage(this.db, polyToBSCRecord(info.polyTxHash), &db.LifecycleStage{Stage: stageBSCRelay, TxHash: signedtx.Hash().Hex(),
+		Sender: this.acc.Address.Hex(), Nonce: &nonce})
+	return this.tracker.Track(signedtx, info.polyTxHash), nil
+}
 
-	FAIL:
-		log.Errorf("failed to relay tx to ethereum: (eth_hash: %s, nonce: %d, poly_hash: %s, eth_explorer: %s origin_price:%d current_price:%d)",
-			hash.String(), nonce, info.polyTxHash, tools.GetExplorerUrl(this.keyStore.GetChainId())+hash.String(), origin.Int64(), info.gasPrice.Int64())
-		if info.gasPrice == maxPrice {
-			log.Fatal("waitTransactionConfirm failed")
-			os.Exit(1)
+// confirmTx waits for the tracked relay, a relay dropped by a reorg goes back
+// to the fee queue, so it passes the sender selection and the queue limits again
+func (this *EthSender) confirmTx(info *EthTxInfo, tracked *tools.TrackedTx) error {
+	nonce := tracked.Tx.Nonce()
+	logger := this.logger(info.polyTxHash).With(log.Fields{log.FieldNonce: nonce})
+	logger.Infof("confirmTx - waiting for the relay")
+	receipt, err := this.waitTransactionConfirm(info.polyTxHash, tracked)
+	if err == errTxDropped {
+		relayed, err := this.isRelayed(info.fromChainId, info.fromTxHash)
+		if err != nil {
+			return fmt.Errorf("commitDepositEventsWithHeader - check if poly_hash %s relayed error: %v", info.polyTxHash, err)
+		}
+		if relayed {
+			logger.Infof("confirmTx - relay was dropped by a reorg but the tx is executed, skip")
+			return nil
 		}
-		info.gasPrice = big.NewInt(0).Quo(big.NewInt(0).Mul(info.gasPrice, big.NewInt(11)), big.NewInt(10))
-		if info.gasPrice.Cmp(maxPrice) > 0 {
-			info.gasPrice.Set(maxPrice)
+		logger.Warnf("confirmTx - relay was dropped by a reorg, relay it again from the fee queue")
+		recordStage(this.db, polyToBSCRecord(info.polyTxHash), &db.LifecycleStage{Stage: stageBSCReceipt,
+			Sender: this.acc.Address.Hex(), Nonce: &nonce, Status: "dropped"})
+		if err = putFeePending(this.db, info.item); err != nil {
+			return fmt.Errorf("commitDepositEventsWithHeader - failed to queue poly_hash %s again: %v", info.polyTxHash, err)
 		}
-		goto RETRY
+		return nil
 	}
-
+	hash := ethcommon.Hash{}
+	if receipt != nil {
+		hash = receipt.TxHash
+	}
+	logger = logger.With(log.Fields{log.FieldBSCTx: hash.String()})
+	stage := &db.LifecycleStage{Stage: stageBSCReceipt, TxHash: hash.Hex(), Sender: this.acc.Address.Hex(), Nonce: &nonce, Status: stageFailed}
+	if receipt != nil {
+		stage.Height = receipt.BlockNumber.Uint64()
+	}
+	if err != nil {
+		stage.Detail = err.Error()
+	} else if receipt.Status == types.ReceiptStatusSuccessful {
+		stage.Status = stageSuccess
+	}
+	recordStage(this.db, polyToBSCRecord(info.polyTxHash), stage)
+	if err == nil && receipt.Status == types.ReceiptStatusSuccessful {
+		logger.Infof("successful to relay tx to ethereum: (origin_price: %d, eth_explorer: %s)",
+			info.originPrice.Int64(), tools.GetExplorerUrl(this.keyStore.GetChainId())+hash.String())
+		return nil
+	}
+	logger.Errorf("failed to relay tx to ethereum: (origin_price: %d, eth_explorer: %s)",
+		info.originPrice.Int64(), tools.GetExplorerUrl(this.keyStore.GetChainId())+hash.String())
+	return nil
 }
 
-func (this *EthSender) commitDepositEventsWithHeader(header *polytypes.Header, param *common2.ToMerkleValue, headerProof string, anchorHeader *polytypes.Header, polyTxHash string, rawAuditPath []byte) bool {
+func (this *EthSender) commitDepositEventsWithHeader(header *polytypes.Header, param *common2.ToMerkleValue, headerProof string, anchorHeader *polytypes.Header, item *FeePending, rawAuditPath []byte, fee *big.Float) int {
+	polyTxHash := item.PolyTxHash
 	var (
 		sigs       []byte
 		headerData []byte
@@ -524,18 +685,13 @@ func (this *EthSender) commitDepositEventsWithHeader(header *polytypes.Header, p
 		}
 	}
 
-	eccdAddr := ethcommon.HexToAddress(this.config.BSCConfig.ECCDContractAddress)
-	eccd, err := eccd_abi.NewEthCrossChainData(eccdAddr, this.ethClient)
-	if err != nil {
-		panic(fmt.Errorf("failed to new eccm: %v", err))
-	}
 	fromTx := [32]byte{}
 	copy(fromTx[:], param.TxHash[:32])
-	res, _ := eccd.CheckIfFromChainTxExist(nil, param.FromChainID, fromTx)
+	res, _ := this.isRelayed(param.FromChainID, fromTx)
 	if res {
 		log.Debugf("already relayed to eth: ( from_chain_id: %d, from_txhash: %x,  param.Txhash: %x)",
 			param.FromChainID, param.TxHash, param.MakeTxParam.TxHash)
-		return true
+		return relayDone
 	}
 	//log.Infof("poly proof with header, height: %d, key: %s, proof: %s", header.Height-1, string(key), proof.AuditPath)
 
@@ -548,15 +704,28 @@ func (this *EthSender) commitDepositEventsWithHeader(header *polytypes.Header, p
 	txData, err := this.contractAbi.Pack("verifyHeaderAndExecuteTx", rawAuditPath, headerData, rawProof, rawAnchor, sigs)
 	if err != nil {
 		log.Errorf("commitDepositEventsWithHeader - err:" + err.Error())
-		return false
+		return relayRetry
 	}
 
-	gasPrice, err := this.ethClient.SuggestGasPrice(context.Background())
+	gasPrice, err := this.gasOracle.SuggestGasPrice(context.Background())
+	if errors.Is(err, tools.ErrGasPriceTooHigh) {
+		log.Warnf("commitDepositEventsWithHeader - poly_hash %s waits for gas price to drop: %v", polyTxHash, err)
+		return relayDeferred
+	}
 	if err != nil {
 		log.Errorf("commitDepositEventsWithHeader - get suggest sas price failed error: %s", err.Error())
-		return false
+		return relayRetry
 	}
 	contractaddr := ethcommon.HexToAddress(this.config.BSCConfig.ECCMContractAddress)
+	if _, err = tools.EthCall(this.config.BSCConfig.URL(), this.restClient, this.acc.Address, contractaddr, txData); err != nil {
+		if revert, ok := err.(*tools.RevertError); ok && revert.Permanent() {
+			log.Errorf("commitDepositEventsWithHeader - skip poly_hash %s, verifyHeaderAndExecuteTx reverts: %s", polyTxHash, revert.Reason)
+			this.skipRelay(polyTxHash, stageFailed, revert.Reason)
+			return relayDone
+		}
+		log.Errorf("commitDepositEventsWithHeader - simulate verifyHeaderAndExecuteTx of poly_hash %s error: %v", polyTxHash, err)
+		return relayRetry
+	}
 	callMsg := ethereum.CallMsg{
 		From: this.acc.Address, To: &contractaddr, Gas: 0, GasPrice: gasPrice,
 		Value: big.NewInt(0), Data: txData,
@@ -564,31 +733,56 @@ func (this *EthSender) commitDepositEventsWithHeader(header *polytypes.Header, p
 	gasLimit, err := this.ethClient.EstimateGas(context.Background(), callMsg)
 	if err != nil {
 		log.Errorf("commitDepositEventsWithHeader - estimate gas limit error: %s", err.Error())
-		return false
+		if isSenderError(err) {
+			this.health.failure(err)
+		}
+		return relayRetry
+	}
+	toContract := ethcommon.BytesToAddress(param.MakeTxParam.ToContractAddress).String()
+	multiplier, maxGasLimit := this.config.GasLimitPolicy(toContract)
+	if maxGasLimit > 0 && gasLimit > maxGasLimit {
+		reason := fmt.Sprintf("estimated gas limit %d is above the ceiling %d of %s", gasLimit, maxGasLimit, toContract)
+		log.Errorf("commitDepositEventsWithHeader - refuse to relay poly_hash %s: %s", polyTxHash, reason)
+		gasLimitRefused.Inc(toContract)
+		this.skipRelay(polyTxHash, stageFailed, reason)
+		return relayDone
+	}
+	gasLimit = uint64(float64(gasLimit) * multiplier)
+	if maxGasLimit > 0 && gasLimit > maxGasLimit {
+		gasLimit = maxGasLimit
 	}
 
-	k := this.getRouter()
-	c, ok := this.cmap[k]
-	if !ok {
-		c = make(chan *EthTxInfo, ChanLen)
-		this.cmap[k] = c
-		go func() {
-			for v := range c {
-				if err = this.sendTxToEth(v); err != nil {
-					log.Errorf("failed to send tx to bsc: error: %v, txData: %s", err, hex.EncodeToString(v.txData))
-				}
-			}
-		}()
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), gasPrice)
+	decision, reason := checkProfit(this.config.BridgeConfig, fee, cost)
+	if decision == feeDefer {
+		deadline := time.Now()
+		if item.DeferredAt > 0 {
+			deadline = time.Unix(item.DeferredAt, 0)
+		}
+		deadline = deadline.Add(time.Duration(this.config.BridgeConfig.MaxDeferTime) * time.Second)
+		if time.Now().Before(deadline) {
+			log.Infof("commitDepositEventsWithHeader - poly_hash %s waits for cheaper gas: %s", polyTxHash, reason)
+			return relayDeferred
+		}
 	}
-	//TODO: could be blocked
-	c <- &EthTxInfo{
+	if decision != feeRelay {
+		log.Errorf("commitDepositEventsWithHeader - skip poly_hash %s, fee too low: %s", polyTxHash, reason)
+		feeTooLow.Inc()
+		this.skipRelay(polyTxHash, stageUnpaid, "fee too low: "+reason)
+		return relayDone
+	}
+
+	this.queue.push(&EthTxInfo{
 		txData:       txData,
 		contractAddr: contractaddr,
 		gasPrice:     gasPrice,
 		gasLimit:     gasLimit,
 		polyTxHash:   polyTxHash,
-	}
-	return true
+		item:         item,
+		fromChainId:  param.FromChainID,
+		fromTxHash:   fromTx,
+	})
+	return relayDone
 }
 
 func (this *EthSender) commitHeader(header *polytypes.Header, pubkList []byte) bool {
@@ -598,7 +792,7 @@ func (this *EthSender) commitHeader(header *polytypes.Header, pubkList []byte) b
 		txErr  error
 		sigs   []byte
 	)
-	gasPrice, err := this.ethClient.SuggestGasPrice(context.Background())
+	gasPrice, err := this.suggestGasPrice()
 	if err != nil {
 		log.Errorf("commitHeader - get suggest sas price failed error: %s", err.Error())
 		return false
@@ -617,6 +811,15 @@ func (this *EthSender) commitHeader(header *polytypes.Header, pubkList []byte) b
 	}
 
 	contractaddr := ethcommon.HexToAddress(this.config.BSCConfig.ECCMContractAddress)
+	if _, err = tools.EthCall(this.config.BSCConfig.URL(), this.restClient, this.acc.Address, contractaddr, txData); err != nil {
+		if revert, ok := err.(*tools.RevertError); ok && revert.Permanent() {
+			log.Errorf("commitHeader - skip poly header %d, changeBookKeeper reverts: %s", header.Height, revert.Reason)
+			this.skip(fmt.Sprintf("header: %d", header.Height), revert.Reason)
+			return true
+		}
+		log.Errorf("commitHeader - simulate changeBookKeeper error: %v", err)
+		return false
+	}
 	callMsg := ethereum.CallMsg{
 		From: this.acc.Address, To: &contractaddr, Gas: 0, GasPrice: gasPrice,
 		Value: big.NewInt(0), Data: txData,
@@ -625,36 +828,111 @@ func (this *EthSender) commitHeader(header *polytypes.Header, pubkList []byte) b
 	gasLimit, err := this.ethClient.EstimateGas(context.Background(), callMsg)
 	if err != nil {
 		log.Errorf("commitHeader - estimate gas limit error: %s", err.Error())
+		if isSenderError(err) {
+			this.health.failure(err)
+		}
 		return true
 	}
+	// the ceiling only guards against target contracts, headers are always committed
+	multiplier, _ := this.config.GasLimitPolicy("")
+	gasLimit = uint64(float64(gasLimit) * multiplier)
 
-	nonce := this.nonceManager.GetAddressNonce(this.acc.Address)
+	nonce, err := this.nonceManager.GetAddressNonce(this.acc.Address)
+	if err != nil {
+		log.Errorf("commitHeader - get nonce error: %s", err.Error())
+		return false
+	}
 	tx := types.NewTransaction(nonce, contractaddr, big.NewInt(0), gasLimit, gasPrice, txData)
 	signedtx, err := this.keyStore.SignTransaction(tx, this.acc)
 	if err != nil {
+		this.nonceManager.ReturnNonce(this.acc.Address, nonce)
 		log.Errorf("commitHeader - sign raw tx error: %s", err.Error())
+		this.health.failure(keyError{err})
 		return false
 	}
 	if err = this.ethClient.SendTransaction(context.Background(), signedtx); err != nil {
+		this.nonceManager.ReturnNonce(this.acc.Address, nonce)
 		log.Errorf("commitHeader - send transaction error:%s\n", err.Error())
+		if isSenderError(err) {
+			this.health.failure(err)
+		}
 		return false
 	}
+	this.health.success()
+	label := fmt.Sprintf("header: %d", header.Height)
+	tracked := this.tracker.Track(signedtx, label)
 
 	hash := header.Hash()
-	txhash := signedtx.Hash()
-	isSuccess := this.waitTransactionConfirm(fmt.Sprintf("header: %d", header.Height), txhash)
-	if isSuccess {
-		log.Infof("successful to relay poly header to ethereum: (header_hash: %s, height: %d, eth_txhash: %s, nonce: %d, eth_explorer: %s)",
-			hash.ToHexString(), header.Height, txhash.String(), nonce, tools.GetExplorerUrl(this.keyStore.GetChainId())+txhash.String())
+	receipt, err := this.waitTransactionConfirm(label, tracked)
+	if err == errTxDropped {
+		log.Warnf("commitHeader - relay of poly header %d was dropped by a reorg, commit it again", header.Height)
+		return false
+	}
+	txhash := ethcommon.Hash{}
+	if receipt != nil {
+		txhash = receipt.TxHash
+	}
+	logger := this.logger("").With(log.Fields{log.FieldHeight: header.Height, log.FieldBSCTx: txhash.String(), log.FieldNonce: nonce})
+	if err == nil && receipt.Status == types.ReceiptStatusSuccessful {
+		logger.Infof("successful to relay poly header to ethereum: (header_hash: %s, eth_explorer: %s)",
+			hash.ToHexString(), tools.GetExplorerUrl(this.keyStore.GetChainId())+txhash.String())
 	} else {
-		log.Errorf("failed to relay poly header to ethereum: (header_hash: %s, height: %d, eth_txhash: %s, nonce: %d, eth_explorer: %s)",
-			hash.ToHexString(), header.Height, txhash.String(), nonce, tools.GetExplorerUrl(this.keyStore.GetChainId())+txhash.String())
+		logger.Errorf("failed to relay poly header to ethereum: (header_hash: %s, eth_explorer: %s)",
+			hash.ToHexString(), tools.GetExplorerUrl(this.keyStore.GetChainId())+txhash.String())
 	}
 	return true
 }
 
-func (this *EthSender) getRouter() string {
-	return strconv.FormatInt(rand.Int63n(this.config.RoutineNum), 10)
+// suggestGasPrice blocks while the gas price is above the configured max price
+func (this *EthSender) suggestGasPrice() (*big.Int, error) {
+	for {
+		gasPrice, err := this.gasOracle.SuggestGasPrice(context.Background())
+		if errors.Is(err, tools.ErrGasPriceTooHigh) {
+			log.Warnf("suggestGasPrice - account %s waits for gas price to drop: %v", this.acc.Address.Hex(), err)
+			time.Sleep(config.GAS_PRICE_WAIT)
+			continue
+		}
+		return gasPrice, err
+	}
+}
+
+// fillNonceGaps sends zero value self transfers for nonces given back by
+// failed sends, otherwise the later transactions of the account never get mined
+func (this *EthSender) fillNonceGaps() {
+	for {
+		time.Sleep(config.NONCE_GAP_CHECK_INTERVAL)
+		gaps, err := this.nonceManager.TakeGaps(this.acc.Address)
+		if err != nil {
+			log.Errorf("fillNonceGaps - account %s: %v", this.acc.Address.Hex(), err)
+			continue
+		}
+		for _, nonce := range gaps {
+			hash, err := this.sendSelfTransfer(nonce)
+			if err != nil {
+				log.Errorf("fillNonceGaps - failed to fill nonce %d of account %s: %v", nonce, this.acc.Address.Hex(), err)
+				this.nonceManager.ReturnNonce(this.acc.Address, nonce)
+				continue
+			}
+			log.Infof("fillNonceGaps - fill nonce %d of account %s with tx %s", nonce, this.acc.Address.Hex(), hash.String())
+		}
+	}
+}
+
+func (this *EthSender) sendSelfTransfer(nonce uint64) (ethcommon.Hash, error) {
+	gasPrice, err := this.suggestGasPrice()
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	tx := types.NewTransaction(nonce, this.acc.Address, big.NewInt(0), tools.TransferGasLimit, gasPrice, nil)
+	signedtx, err := this.keyStore.SignTransaction(tx, this.acc)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	if err = this.ethClient.SendTransaction(context.Background(), signedtx); err != nil {
+		return ethcommon.Hash{}, err
+	}
+	this.tracker.Track(signedtx, "nonce gap")
+	return signedtx.Hash(), nil
 }
 
 func (this *EthSender) Balance() (*big.Int, error) {
@@ -665,27 +943,92 @@ func (this *EthSender) Balance() (*big.Int, error) {
 	return balance, nil
 }
 
-// TODO: check the status of tx
-func (this *EthSender) waitTransactionConfirm(polyTxHash string, hash ethcommon.Hash) bool {
-	start := time.Now()
+// skipRelay skips the relay of the poly tx, the status is stageFailed or stageUnpaid
+func (this *EthSender) skipRelay(polyTxHash, status, reason string) {
+	recordStage(this.db, polyToBSCRecord(polyTxHash), &db.LifecycleStage{Stage: stageSkipped, Status: status, Detail: reason})
+	this.skip(polyTxHash, reason)
+}
+
+func (this *EthSender) skip(key, reason string) {
+	if err := this.db.PutSkip(key, reason); err != nil {
+		log.Errorf("skip - failed to record skip reason of %s: %v", key, err)
+	}
+}
+
+// isRelayed checks the ECCD contract for a cross chain tx already executed on BSC
+func (this *EthSender) isRelayed(fromChainId uint64, fromTxHash [32]byte) (bool, error) {
+	eccdAddr := ethcommon.HexToAddress(this.config.BSCConfig.ECCDContractAddress)
+	eccd, err := eccd_abi.NewEthCrossChainData(eccdAddr, this.ethClient)
+	if err != nil {
+		panic(fmt.Errorf("failed to new eccm: %v", err))
+	}
+	return eccd.CheckIfFromChainTxExist(nil, fromChainId, fromTxHash)
+}
+
+// waitTransactionConfirm waits until the tracker sees a tx with the nonce of the
+// tracked tx mined, the tracker may have replaced the tx we sent so the receipt
+// of the mined tx is returned.
+// With RelayConfirmations set it then waits for the confirmations and returns
+// errTxDropped if the tx left the canonical chain and the pool.
+func (this *EthSender) waitTransactionConfirm(polyTxHash string, tracked *tools.TrackedTx) (*types.Receipt, error) {
+	nonce := tracked.Tx.Nonce()
+	receipt, err := tracked.Wait()
+	if err != nil {
+		log.Errorf("waitTransactionConfirm - ( nonce %d, poly_tx %s ) failed: %v", nonce, polyTxHash, err)
+		return receipt, err
+	}
+	log.Debugf("( eth_transaction %s, poly_tx %s ) is mined with status %d", receipt.TxHash.String(), polyTxHash, receipt.Status)
+	if this.config.BSCConfig.RelayConfirmations == 0 {
+		return receipt, nil
+	}
+	receipt, err = this.waitConfirmations(receipt)
+	if err == errTxDropped {
+		// the nonce is free again, given back for the next relay or filled as a gap
+		this.nonceManager.ReturnNonce(this.acc.Address, nonce)
+	}
+	return receipt, err
+}
+
+// waitConfirmations polls until the block of the receipt is RelayConfirmations deep
+// in the canonical chain. A reorg puts the tx back into the pool where it is mined
+// again, so the tx only counts as dropped when the node does not know it anymore.
+func (this *EthSender) waitConfirmations(receipt *types.Receipt) (*types.Receipt, error) {
+	hash := receipt.TxHash
 	for {
-		if time.Now().After(start.Add(time.Minute * 3)) {
-			return false
+		time.Sleep(config.BSC_MONITOR_INTERVAL)
+		head, err := this.ethClient.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			log.Errorf("waitConfirmations - get head of bsc error: %v", err)
+			continue
+		}
+		current, err := this.ethClient.TransactionReceipt(context.Background(), hash)
+		if err == ethereum.NotFound {
+			_, isPending, err := this.ethClient.TransactionByHash(context.Background(), hash)
+			if err == ethereum.NotFound {
+				log.Warnf("waitConfirmations - eth_transaction %s in block %d is dropped", hash.String(), receipt.BlockNumber.Uint64())
+				return receipt, errTxDropped
+			}
+			if err == nil && isPending {
+				log.Infof("waitConfirmations - eth_transaction %s is back in the pool after a reorg", hash.String())
+			}
+			continue
 		}
-		time.Sleep(time.Second * 1)
-		_, ispending, err := this.ethClient.TransactionByHash(context.Background(), hash)
 		if err != nil {
+			log.Errorf("waitConfirmations - get receipt of %s error: %v", hash.String(), err)
 			continue
 		}
-		log.Debugf("( eth_transaction %s, poly_tx %s ) is pending: %v", hash.String(), polyTxHash, ispending)
-		if ispending == true {
+		canonical, err := this.ethClient.HeaderByNumber(context.Background(), current.BlockNumber)
+		if err != nil {
+			log.Errorf("waitConfirmations - get header %d error: %v", current.BlockNumber.Uint64(), err)
 			continue
-		} else {
-			receipt, err := this.ethClient.TransactionReceipt(context.Background(), hash)
-			if err != nil {
-				continue
-			}
-			return receipt.Status == types.ReceiptStatusSuccessful
+		}
+		if canonical.Hash() != current.BlockHash {
+			log.Warnf("waitConfirmations - block %s of eth_transaction %s is not canonical anymore", current.BlockHash.String(), hash.String())
+			continue
+		}
+		if head.Number.Cmp(current.BlockNumber) >= 0 &&
+			new(big.Int).Sub(head.Number, current.BlockNumber).Uint64() >= this.config.BSCConfig.RelayConfirmations {
+			return current, nil
 		}
 	}
 }
@@ -696,4 +1039,8 @@ type EthTxInfo struct {
 	gasPrice     *big.Int
 	contractAddr ethcommon.Address
 	polyTxHash   string
+	fromChainId  uint64
+	fromTxHash   [32]byte
+	originPrice  *big.Int
+	item         *FeePending // position on poly, a relay dropped by a reorg goes back to the fee queue with it
 }
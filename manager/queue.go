@@ -0,0 +1,105 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"encoding/hex"
+	"sync/atomic"
+
+	"github.com/polynetwork/bsc-relayer/log"
+	"github.com/polynetwork/bsc-relayer/tools"
+)
+
+const defaultSendQueueSize = 64
+
+// sendQueue is the bounded FIFO of the relays of one sender. The dispatcher
+// sends them one by one in queue order, then up to workers goroutines wait
+// for the confirmations. The depth counts a relay from its push until it is
+// confirmed or its send failed.
+type sendQueue struct {
+	sender   string
+	ch       chan *EthTxInfo
+	workers  chan struct{}
+	inflight int32
+}
+
+func newSendQueue(sender string, size, workers int) *sendQueue {
+	if size <= 0 {
+		size = defaultSendQueueSize
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	return &sendQueue{
+		sender:  sender,
+		ch:      make(chan *EthTxInfo, size),
+		workers: make(chan struct{}, workers),
+	}
+}
+
+// push blocks while the queue is full
+func (this *sendQueue) push(info *EthTxInfo) {
+	this.ch <- info
+	this.report()
+}
+
+func (this *sendQueue) full() bool {
+	return len(this.ch) >= cap(this.ch)
+}
+
+// depth counts the relays queued and the ones waiting for confirmation
+func (this *sendQueue) depth() int {
+	return len(this.ch) + int(atomic.LoadInt32(&this.inflight))
+}
+
+// report sets the depth gauge of the sender
+func (this *sendQueue) report() {
+	sendQueueDepth.Set(float64(this.depth()), this.sender)
+}
+
+// done removes a relay taken by the dispatcher from the depth
+func (this *sendQueue) done() {
+	atomic.AddInt32(&this.inflight, -1)
+	<-this.workers
+	this.report()
+}
+
+// dispatch sends the queued relays of the sender in order
+func (this *EthSender) dispatch() {
+	for info := range this.queue.ch {
+		this.queue.workers <- struct{}{}
+		atomic.AddInt32(&this.queue.inflight, 1)
+
+		tracked, err := this.sendTxToEth(info)
+		if err != nil {
+			this.queue.done()
+			log.Errorf("failed to send tx to bsc: error: %v, txData: %s", err, hex.EncodeToString(info.txData))
+			if isSenderError(err) {
+				this.health.failure(err)
+			}
+			continue
+		}
+		this.health.success()
+
+		go func(info *EthTxInfo, tracked *tools.TrackedTx) {
+			defer this.queue.done()
+			if err := this.confirmTx(info, tracked); err != nil {
+				log.Errorf("failed to confirm tx to bsc: error: %v, poly_hash: %s", err, info.polyTxHash)
+			}
+		}(info, tracked)
+	}
+}
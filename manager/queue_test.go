@@ -0,0 +1,56 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendQueue(t *testing.T) {
+	q := newSendQueue("0x01", 2, 0)
+	assert.Equal(t, 1, cap(q.workers))
+	q.push(&EthTxInfo{polyTxHash: "1"})
+	assert.False(t, q.full())
+	assert.Equal(t, float64(1), sendQueueDepth.Get("0x01"))
+	q.push(&EthTxInfo{polyTxHash: "2"})
+	assert.True(t, q.full())
+	q.inflight = 1
+	q.workers <- struct{}{}
+	assert.Equal(t, 3, q.depth())
+	q.done()
+	assert.Equal(t, 2, q.depth())
+	assert.Equal(t, float64(2), sendQueueDepth.Get("0x01"))
+
+	// a push to a full queue waits for the dispatcher
+	pushed := make(chan struct{})
+	go func() {
+		q.push(&EthTxInfo{polyTxHash: "3"})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push to a full queue returned")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, "1", (<-q.ch).polyTxHash)
+	<-pushed
+	assert.Equal(t, "2", (<-q.ch).polyTxHash)
+	assert.Equal(t, "3", (<-q.ch).polyTxHash)
+}
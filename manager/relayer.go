@@ -0,0 +1,98 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/log"
+	"github.com/polynetwork/bsc-relayer/tools"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/common"
+)
+
+// checkPolyRelayer makes sure poly accepts the headers and proofs of the
+// relayer: SideChainId is registered on poly and the signers are approved relayers
+func checkPolyRelayer(cfg *config.ServiceConfig, polySdk *sdk.PolySdk, addrs ...common.Address) error {
+	sideChain, err := tools.GetPolySideChain(polySdk, cfg.BSCConfig.SideChainId)
+	if err != nil {
+		return fmt.Errorf("checkPolyRelayer - failed to get side chain %d: %v", cfg.BSCConfig.SideChainId, err)
+	}
+	if sideChain == nil {
+		return fmt.Errorf("checkPolyRelayer - SideChainId %d is not registered on poly, check BSCConfig.SideChainId",
+			cfg.BSCConfig.SideChainId)
+	}
+	eccm := ethcommon.HexToAddress(cfg.BSCConfig.ECCMContractAddress)
+	if !bytes.Equal(sideChain.CCMCAddress, eccm.Bytes()) {
+		log.Warnf("checkPolyRelayer - side chain %d (%s) is registered with ECCM %s, the config has %s",
+			sideChain.ChainId, sideChain.Name, ethcommon.BytesToAddress(sideChain.CCMCAddress).Hex(), eccm.Hex())
+	}
+
+	failed := make([]string, 0)
+	for _, addr := range addrs {
+		ok, err := tools.IsPolyRelayer(polySdk, addr)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("failed to get relayer status of %s: %v", addr.ToBase58(), err))
+			continue
+		}
+		if !ok {
+			polyRelayerApproved.Set(0, addr.ToBase58())
+			failed = append(failed, fmt.Sprintf("poly signer %s is not an approved relayer", addr.ToBase58()))
+			continue
+		}
+		polyRelayerApproved.Set(1, addr.ToBase58())
+		log.Infof("checkPolyRelayer - poly signer %s is an approved relayer of side chain %d (%s)",
+			addr.ToBase58(), sideChain.ChainId, sideChain.Name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("checkPolyRelayer - %s, register the signers with register-relayer and wait for the approval",
+			strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// MonitorRelayer repeats checkPolyRelayer, a revoked registration stops the
+// relaying to poly and is only reported here
+func (this *BSCManager) MonitorRelayer() {
+	ticker := time.NewTicker(config.RELAYER_CHECK_INTERVAL)
+	for {
+		select {
+		case <-ticker.C:
+			if err := checkPolyRelayer(this.config, this.polySdk, this.polyAddresses()...); err != nil {
+				log.Errorf("MonitorRelayer - %v", err)
+			}
+		case <-this.exitChan:
+			return
+		}
+	}
+}
+
+// polyAddresses are the header signer and the proof signers
+func (this *BSCManager) polyAddresses() []common.Address {
+	addrs := []common.Address{this.polyAddress}
+	for _, v := range this.proofSigners {
+		if v.address != this.polyAddress {
+			addrs = append(addrs, v.address)
+		}
+	}
+	return addrs
+}
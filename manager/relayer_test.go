@@ -0,0 +1,111 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/native/service/governance/relayer_manager"
+	"github.com/polynetwork/poly/native/service/governance/side_chain_manager"
+	autils "github.com/polynetwork/poly/native/service/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPolyRelayer(t *testing.T) {
+	// serialize without the poly ledger
+	side_chain_manager.Test = true
+	defer func() { side_chain_manager.Test = false }()
+	sink := common.NewZeroCopySink(nil)
+	sideChain := &side_chain_manager.SideChain{ChainId: 79, Name: "bsc", CCMCAddress: []byte{0xee}}
+	if !assert.NoError(t, sideChain.Serialization(sink)) {
+		return
+	}
+	approved, unapproved, failing := common.Address{1}, common.Address{2}, common.Address{3}
+	storage := map[string][]byte{
+		autils.SideChainManagerContractAddress.ToHexString() +
+			hex.EncodeToString(append([]byte(side_chain_manager.SIDE_CHAIN), autils.GetUint64Bytes(79)...)): sink.Bytes(),
+		autils.RelayerManagerContractAddress.ToHexString() +
+			hex.EncodeToString(append([]byte(relayer_manager.RELAYER), approved[:]...)): {1},
+		autils.RelayerManagerContractAddress.ToHexString() +
+			hex.EncodeToString(append([]byte(relayer_manager.RELAYER), failing[:]...)): nil,
+	}
+	// getstorage of poly, an unknown key is empty storage and a nil value an RPC error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ID     string   `json:"id"`
+			Params []string `json:"params"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Params) != 2 {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"%s","error":42002,"desc":"INVALID PARAMS"}`, req.ID)
+			return
+		}
+		value, ok := storage[req.Params[0]+req.Params[1]]
+		if ok && value == nil {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"%s","error":42001,"desc":"SESSION EXPIRED"}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"%s","error":0,"desc":"SUCCESS","result":"%s"}`, req.ID, hex.EncodeToString(value))
+	}))
+	defer srv.Close()
+	polySdk := sdk.NewPolySdk()
+	polySdk.NewRpcClient().SetAddress(srv.URL)
+
+	for _, c := range []struct {
+		name    string
+		chainId uint64
+		addrs   []common.Address
+		errs    []string
+	}{
+		{"approved", 79, []common.Address{approved}, nil},
+		{"not registered", 80, []common.Address{approved}, []string{"SideChainId 80 is not registered"}},
+		{"unapproved", 79, []common.Address{unapproved}, []string{unapproved.ToBase58() + " is not an approved relayer"}},
+		{"all signers reported", 79, []common.Address{unapproved, approved, failing}, []string{
+			unapproved.ToBase58() + " is not an approved relayer",
+			"failed to get relayer status of " + failing.ToBase58(),
+		}},
+	} {
+		cfg := &config.ServiceConfig{BSCConfig: &config.BSCConfig{SideChainId: c.chainId, ECCMContractAddress: "0xee"}}
+		err := checkPolyRelayer(cfg, polySdk, c.addrs...)
+		if len(c.errs) == 0 {
+			assert.NoError(t, err, c.name)
+			continue
+		}
+		if !assert.Error(t, err, c.name) {
+			continue
+		}
+		for _, v := range c.errs {
+			assert.Contains(t, err.Error(), v, c.name)
+		}
+	}
+	assert.Equal(t, float64(1), polyRelayerApproved.Get(approved.ToBase58()))
+	assert.Equal(t, float64(0), polyRelayerApproved.Get(unapproved.ToBase58()))
+
+	// a revoked registration is reported by the gauge
+	delete(storage, autils.RelayerManagerContractAddress.ToHexString()+
+		hex.EncodeToString(append([]byte(relayer_manager.RELAYER), approved[:]...)))
+	assert.Error(t, checkPolyRelayer(&config.ServiceConfig{BSCConfig: &config.BSCConfig{SideChainId: 79}}, polySdk, approved))
+	assert.Equal(t, float64(0), polyRelayerApproved.Get(approved.ToBase58()))
+}
@@ -0,0 +1,162 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/polynetwork/bsc-relayer/log"
+)
+
+const (
+	SelectorBalance      = "balance"
+	SelectorRoundRobin   = "round-robin"
+	SelectorLeastPending = "least-pending"
+
+	defaultBalanceCacheTime = 30 * time.Second
+)
+
+// SenderState is what a selector knows about a sender able to relay
+type SenderState struct {
+	Sender  *EthSender
+	Balance *big.Int
+	Pending int
+}
+
+// SenderSelector picks the sender of the next relay, states is never empty
+type SenderSelector interface {
+	Select(states []*SenderState) *EthSender
+}
+
+func NewSenderSelector(name string) (SenderSelector, error) {
+	switch name {
+	case "", SelectorBalance:
+		return &BalanceWeightedSelector{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+	case SelectorRoundRobin:
+		return &RoundRobinSelector{}, nil
+	case SelectorLeastPending:
+		return &LeastPendingSelector{}, nil
+	default:
+		return nil, fmt.Errorf("NewSenderSelector - unknown sender selector %s", name)
+	}
+}
+
+// BalanceWeightedSelector picks senders at random, weighted by their balances
+type BalanceWeightedSelector struct {
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func (this *BalanceWeightedSelector) Select(states []*SenderState) *EthSender {
+	sum := big.NewInt(0)
+	for _, v := range states {
+		sum.Add(sum, v.Balance)
+	}
+	if sum.Sign() <= 0 {
+		return states[0].Sender
+	}
+	this.lock.Lock()
+	point := new(big.Int).Rand(this.rand, sum)
+	this.lock.Unlock()
+
+	acc := big.NewInt(0)
+	for _, v := range states {
+		acc.Add(acc, v.Balance)
+		if acc.Cmp(point) > 0 {
+			return v.Sender
+		}
+	}
+	return states[len(states)-1].Sender
+}
+
+// RoundRobinSelector takes the senders in turn
+type RoundRobinSelector struct {
+	next uint64
+}
+
+func (this *RoundRobinSelector) Select(states []*SenderState) *EthSender {
+	i := atomic.AddUint64(&this.next, 1) - 1
+	return states[i%uint64(len(states))].Sender
+}
+
+// LeastPendingSelector picks the sender with the fewest unmined transactions,
+// the richer one on a tie
+type LeastPendingSelector struct{}
+
+func (this *LeastPendingSelector) Select(states []*SenderState) *EthSender {
+	best := states[0]
+	for _, v := range states[1:] {
+		if v.Pending < best.Pending || (v.Pending == best.Pending && v.Balance.Cmp(best.Balance) > 0) {
+			best = v
+		}
+	}
+	return best.Sender
+}
+
+type cachedBalance struct {
+	balance *big.Int
+	at      time.Time
+}
+
+// balanceCache keeps sender balances for ttl, a stale balance is used when
+// the node fails to return a fresh one
+type balanceCache struct {
+	ttl   time.Duration
+	fetch func(*EthSender) (*big.Int, error)
+
+	lock  sync.Mutex
+	items map[ethcommon.Address]*cachedBalance
+}
+
+func newBalanceCache(ttl time.Duration, fetch func(*EthSender) (*big.Int, error)) *balanceCache {
+	if ttl <= 0 {
+		ttl = defaultBalanceCacheTime
+	}
+	return &balanceCache{ttl: ttl, fetch: fetch, items: make(map[ethcommon.Address]*cachedBalance)}
+}
+
+func (this *balanceCache) get(sender *EthSender) (*big.Int, error) {
+	this.lock.Lock()
+	item, ok := this.items[sender.acc.Address]
+	this.lock.Unlock()
+	if ok && time.Since(item.at) < this.ttl {
+		return item.balance, nil
+	}
+
+	balance, err := this.fetch(sender)
+	if err != nil {
+		if ok {
+			log.Warnf("balanceCache - use balance of %s cached at %s: %v", sender.acc.Address.Hex(), item.at.Format(time.RFC3339), err)
+			return item.balance, nil
+		}
+		return nil, err
+	}
+	this.set(sender, balance)
+	return balance, nil
+}
+
+func (this *balanceCache) set(sender *EthSender, balance *big.Int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.items[sender.acc.Address] = &cachedBalance{balance: balance, at: time.Now()}
+}
@@ -0,0 +1,116 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStates(balances ...*big.Int) []*SenderState {
+	states := make([]*SenderState, len(balances))
+	for i, v := range balances {
+		sender := &EthSender{acc: accounts.Account{Address: ethcommon.BigToAddress(big.NewInt(int64(i + 1)))}}
+		states[i] = &SenderState{Sender: sender, Balance: v}
+	}
+	return states
+}
+
+func bnb(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+func TestBalanceWeightedSelector(t *testing.T) {
+	selector, err := NewSenderSelector(SelectorBalance)
+	assert.NoError(t, err)
+
+	// the sum of these balances does not fit into int64
+	states := newTestStates(bnb(1000), bnb(3000), big.NewInt(0))
+	counts := make(map[*EthSender]int)
+	for i := 0; i < 4000; i++ {
+		counts[selector.Select(states)]++
+	}
+	assert.Equal(t, 0, counts[states[2].Sender])
+	assert.InDelta(t, 1000, counts[states[0].Sender], 200)
+	assert.InDelta(t, 3000, counts[states[1].Sender], 200)
+
+	// empty accounts are still picked when nothing else is left
+	states = newTestStates(big.NewInt(0))
+	assert.Equal(t, states[0].Sender, selector.Select(states))
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	selector, err := NewSenderSelector(SelectorRoundRobin)
+	assert.NoError(t, err)
+	states := newTestStates(bnb(1), bnb(1), bnb(1))
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, states[i%3].Sender, selector.Select(states))
+	}
+}
+
+func TestLeastPendingSelector(t *testing.T) {
+	selector, err := NewSenderSelector(SelectorLeastPending)
+	assert.NoError(t, err)
+	states := newTestStates(bnb(1), bnb(2), bnb(3))
+	states[0].Pending = 1
+	states[1].Pending = 0
+	states[2].Pending = 0
+	assert.Equal(t, states[2].Sender, selector.Select(states))
+	states[2].Pending = 4
+	assert.Equal(t, states[1].Sender, selector.Select(states))
+
+	_, err = NewSenderSelector("unknown")
+	assert.Error(t, err)
+}
+
+func TestBalanceCache(t *testing.T) {
+	calls := 0
+	var fail bool
+	cache := newBalanceCache(time.Hour, func(*EthSender) (*big.Int, error) {
+		calls++
+		if fail {
+			return nil, errors.New("node down")
+		}
+		return big.NewInt(int64(calls)), nil
+	})
+	sender := newTestStates(nil)[0].Sender
+
+	fail = true
+	_, err := cache.get(sender)
+	assert.Error(t, err)
+
+	fail = false
+	bal, err := cache.get(sender)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(2), bal)
+	bal, _ = cache.get(sender)
+	assert.Equal(t, big.NewInt(2), bal)
+	assert.Equal(t, 2, calls)
+
+	// a stale balance is kept while the node fails
+	cache.ttl = 0
+	fail = true
+	bal, err = cache.get(sender)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(2), bal)
+}
@@ -0,0 +1,297 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/log"
+	"github.com/polynetwork/bsc-relayer/tools"
+)
+
+const (
+	topUpSent     = "sent"
+	topUpMined    = "mined"
+	topUpReverted = "reverted"
+	topUpFailed   = "failed"
+	topUpCapped   = "capped"
+)
+
+// topUpAudit is one line of the audit log, written for every top-up attempt
+// and once more when the top-up is mined
+type topUpAudit struct {
+	Time     string
+	Sender   string
+	Treasury string
+	Balance  string `json:",omitempty"` // wei, balance of the sender before the top-up
+	Amount   string `json:",omitempty"` // wei
+	SentDay  string `json:",omitempty"` // wei, sent by the treasury on the UTC day before the top-up
+	TxHash   string `json:",omitempty"`
+	Status   string // sent, mined, reverted, failed or capped
+	Error    string `json:",omitempty"`
+}
+
+// treasury tops up the senders running low, one top-up per sender at a time
+type treasury struct {
+	cfg       *config.TopUpConfig
+	acc       accounts.Account
+	keyStore  *tools.EthKeyStore
+	ethClient *ethclient.Client
+	gasOracle *tools.BoundedGasOracle
+	db        *db.BoltDB
+	auditLog  string
+
+	pending map[ethcommon.Address]ethcommon.Hash
+}
+
+func newTreasury(cfg *config.TopUpConfig, chainId *big.Int, senders []*EthSender, ethClient *ethclient.Client,
+	gasOracle *tools.BoundedGasOracle, boltDB *db.BoltDB) (*treasury, error) {
+	if cfg.TreasuryKeyStore == "" {
+		return nil, fmt.Errorf("newTreasury - TreasuryKeyStore is not set")
+	}
+	ks, acc, err := tools.NewSingleAccountKeyStore(cfg.TreasuryKeyStore, cfg.TreasuryAccount, cfg.TreasuryPwd, chainId)
+	if err != nil {
+		return nil, fmt.Errorf("newTreasury - %v", err)
+	}
+	for _, v := range senders {
+		if v.acc.Address == acc.Address {
+			return nil, fmt.Errorf("newTreasury - treasury %s is also a sender", acc.Address.Hex())
+		}
+	}
+	auditLog := cfg.AuditLog
+	if auditLog == "" {
+		auditLog = config.TOP_UP_AUDIT_LOG
+	}
+	log.Infof("treasury %s tops up senders below %s wei to %s wei", acc.Address.Hex(),
+		config.Wei(cfg.AlertBalance).String(), config.Wei(cfg.TargetBalance).String())
+	// top-ups sent before a restart are settled before the sender gets another
+	stored, err := boltDB.GetPendingTopUps()
+	if err != nil {
+		return nil, fmt.Errorf("newTreasury - failed to load pending top-ups: %v", err)
+	}
+	pending := make(map[ethcommon.Address]ethcommon.Hash, len(stored))
+	for k, v := range stored {
+		pending[ethcommon.BytesToAddress([]byte(k))] = ethcommon.BytesToHash(v)
+		log.Infof("newTreasury - top-up %s of sender %s is pending", ethcommon.BytesToHash(v).Hex(), ethcommon.BytesToAddress([]byte(k)).Hex())
+	}
+	return &treasury{
+		cfg:       cfg,
+		acc:       acc,
+		keyStore:  ks,
+		ethClient: ethClient,
+		gasOracle: gasOracle,
+		db:        boltDB,
+		auditLog:  auditLog,
+		pending:   pending,
+	}, nil
+}
+
+// topUpAmount is what brings balance to target, cut down to what is left of the
+// daily cap, a zero cap means no limit
+func topUpAmount(balance, target, sentToday, dailyCap *big.Int) *big.Int {
+	amount := new(big.Int).Sub(target, balance)
+	if amount.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	if dailyCap.Sign() > 0 {
+		left := new(big.Int).Sub(dailyCap, sentToday)
+		if left.Sign() <= 0 {
+			return big.NewInt(0)
+		}
+		if amount.Cmp(left) > 0 {
+			amount = left
+		}
+	}
+	return amount
+}
+
+// settle looks for the receipts of the top-ups sent before
+func (this *treasury) settle() {
+	for sender, hash := range this.pending {
+		receipt, err := this.ethClient.TransactionReceipt(context.Background(), hash)
+		if err == ethereum.NotFound {
+			if _, _, err = this.ethClient.TransactionByHash(context.Background(), hash); err == ethereum.NotFound {
+				// never sent before a restart or dropped by the node
+				this.unpend(sender)
+				topUpFailures.Inc(sender.Hex())
+				this.audit(&topUpAudit{Sender: sender.Hex(), TxHash: hash.Hex(), Status: topUpFailed, Error: "unknown to the node"})
+				continue
+			}
+			log.Infof("settle - top-up %s of sender %s is not mined yet", hash.Hex(), sender.Hex())
+			continue
+		}
+		if err != nil {
+			log.Errorf("settle - failed to get receipt of top-up %s: %v", hash.Hex(), err)
+			continue
+		}
+		this.unpend(sender)
+		status := topUpMined
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			status = topUpReverted
+			topUpFailures.Inc(sender.Hex())
+		}
+		this.audit(&topUpAudit{Sender: sender.Hex(), TxHash: hash.Hex(), Status: status})
+	}
+}
+
+func (this *treasury) unpend(sender ethcommon.Address) {
+	delete(this.pending, sender)
+	if err := this.db.DeletePendingTopUp(sender.Bytes()); err != nil {
+		log.Errorf("unpend - failed to delete top-up of sender %s: %v", sender.Hex(), err)
+	}
+}
+
+func (this *treasury) topUp(sender ethcommon.Address, balance *big.Int) {
+	if hash, ok := this.pending[sender]; ok {
+		log.Infof("topUp - sender %s waits for top-up %s", sender.Hex(), hash.Hex())
+		return
+	}
+	day := time.Now().UTC().Format("2006-01-02")
+	sent := this.db.GetTopUp(day)
+	entry := &topUpAudit{Sender: sender.Hex(), Balance: balance.String(), SentDay: sent.String()}
+	amount := topUpAmount(balance, config.Wei(this.cfg.TargetBalance), sent, config.Wei(this.cfg.DailyCap))
+	if amount.Sign() <= 0 {
+		entry.Status = topUpCapped
+		topUpFailures.Inc(sender.Hex())
+		log.Errorf("topUp - daily cap of the treasury is reached, sender %s is not topped up", sender.Hex())
+		this.audit(entry)
+		return
+	}
+	entry.Amount = amount.String()
+
+	hash, err := this.send(sender, amount)
+	if err != nil {
+		entry.Status, entry.Error = topUpFailed, err.Error()
+		topUpFailures.Inc(sender.Hex())
+		log.Errorf("topUp - failed to top up sender %s: %v", sender.Hex(), err)
+		this.audit(entry)
+		return
+	}
+	if _, err = this.db.AddTopUp(day, amount); err != nil {
+		log.Errorf("topUp - failed to record top-up %s: %v", hash.Hex(), err)
+	}
+	entry.Status, entry.TxHash = topUpSent, hash.Hex()
+	topUpTotal.Add(weiToBNB(amount), sender.Hex())
+	this.audit(entry)
+}
+
+// send signs the top-up and records it as pending before it is sent, so that a
+// restart does not send the sender a second one
+func (this *treasury) send(to ethcommon.Address, amount *big.Int) (ethcommon.Hash, error) {
+	nonce, err := this.ethClient.PendingNonceAt(context.Background(), this.acc.Address)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	gasPrice, err := this.gasOracle.SuggestGasPrice(context.Background())
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	tx := types.NewTransaction(nonce, to, amount, tools.TransferGasLimit, gasPrice, nil)
+	signedtx, err := this.keyStore.SignTransaction(tx, this.acc)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	if err = this.db.PutPendingTopUp(to.Bytes(), signedtx.Hash().Bytes()); err != nil {
+		return ethcommon.Hash{}, err
+	}
+	if err = this.ethClient.SendTransaction(context.Background(), signedtx); err != nil {
+		if dbErr := this.db.DeletePendingTopUp(to.Bytes()); dbErr != nil {
+			log.Errorf("send - failed to delete top-up %s: %v", signedtx.Hash().Hex(), dbErr)
+		}
+		return ethcommon.Hash{}, err
+	}
+	this.pending[to] = signedtx.Hash()
+	return signedtx.Hash(), nil
+}
+
+func (this *treasury) audit(entry *topUpAudit) {
+	entry.Time = time.Now().UTC().Format(time.RFC3339)
+	entry.Treasury = this.acc.Address.Hex()
+	data, _ := json.Marshal(entry)
+	log.Infof("top-up audit: %s", data)
+
+	f, err := os.OpenFile(this.auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Errorf("audit - failed to open %s: %v", this.auditLog, err)
+		return
+	}
+	defer f.Close()
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		log.Errorf("audit - failed to write %s: %v", this.auditLog, err)
+	}
+}
+
+// MonitorBalances checks the balances of the senders, warns about the ones
+// below the alert balance and tops them up when a treasury is configured
+func (this *PolyManager) MonitorBalances() {
+	cfg := this.config.BSCConfig.TopUp
+	if cfg == nil {
+		return
+	}
+	interval := time.Duration(cfg.CheckInterval) * time.Second
+	if interval <= 0 {
+		interval = config.BALANCE_CHECK_INTERVAL
+	}
+	this.checkBalances()
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-ticker.C:
+			this.checkBalances()
+		case <-this.exitChan:
+			return
+		}
+	}
+}
+
+func (this *PolyManager) checkBalances() {
+	if this.treasury != nil {
+		this.treasury.settle()
+	}
+	alert := config.Wei(this.config.BSCConfig.TopUp.AlertBalance)
+	for _, v := range this.senders {
+		addr := v.acc.Address.Hex()
+		balance, err := v.Balance()
+		if err != nil {
+			log.Errorf("checkBalances - failed to get balance of %s: %v", addr, err)
+			continue
+		}
+		this.balances.set(v, balance)
+		senderBalance.Set(weiToBNB(balance), addr)
+		if balance.Cmp(alert) >= 0 {
+			senderLowBalance.Set(0, addr)
+			continue
+		}
+		senderLowBalance.Set(1, addr)
+		log.Warnf("checkBalances - sender %s is running low: %s wei, alert balance %s wei", addr, balance.String(), alert.String())
+		if this.treasury != nil {
+			this.treasury.topUp(v.acc.Address, balance)
+		}
+	}
+}
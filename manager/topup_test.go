@@ -0,0 +1,57 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopUpAmount(t *testing.T) {
+	target := big.NewInt(1000)
+	for _, c := range []struct {
+		balance, sent, cap, expect int64
+	}{
+		{balance: 200, sent: 0, cap: 0, expect: 800},
+		{balance: 200, sent: 5000, cap: 0, expect: 800},
+		{balance: 200, sent: 100, cap: 2000, expect: 800},
+		{balance: 200, sent: 1500, cap: 2000, expect: 500},
+		{balance: 200, sent: 2000, cap: 2000, expect: 0},
+		{balance: 200, sent: 2500, cap: 2000, expect: 0},
+		{balance: 1200, sent: 0, cap: 2000, expect: 0},
+	} {
+		amount := topUpAmount(big.NewInt(c.balance), target, big.NewInt(c.sent), big.NewInt(c.cap))
+		assert.Equal(t, c.expect, amount.Int64(), "balance %d sent %d cap %d", c.balance, c.sent, c.cap)
+	}
+}
+
+func TestTopUpConfig(t *testing.T) {
+	// amounts above the 18.4 BNB of an uint64
+	cfg := &config.TopUpConfig{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"TreasuryAccount": "0x01", "AlertBalance": 20000000000000000000,
+		"TargetBalance": 50000000000000000000}`), cfg))
+	assert.True(t, cfg.Enabled())
+	assert.Equal(t, "50000000000000000000", cfg.TargetBalance.String())
+	assert.Equal(t, int64(0), config.Wei(cfg.DailyCap).Int64())
+
+	cfg.TargetBalance = nil
+	assert.False(t, cfg.Enabled())
+}
@@ -0,0 +1,148 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/log"
+)
+
+// ReplacedTx is the answer of TxHandler
+type ReplacedTx struct {
+	Hash     string
+	GasPrice string
+}
+
+// AdminAuth guards the admin endpoints. Requests of browsers, which carry an
+// Origin header, are refused so that no web page can reach them. With token
+// every request needs "Authorization: Bearer <token>", without it only GET and
+// HEAD are served and only for a loopback Host, so that a rebound DNS name
+// reads nothing either.
+func AdminAuth(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if token != "" {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !config.IsLoopback(host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "AdminToken is not set, the admin endpoints are read only", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LifecycleHandler serves the records of ?key=<tx hash or cross chain ID> as JSON
+func LifecycleHandler(boltDB *db.BoltDB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "key is missing", http.StatusBadRequest)
+			return
+		}
+		records, err := boltDB.GetLifecycles(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	})
+}
+
+// TxHandler replaces a pending bsc tx of a sender on POST, as the speedup and
+// cancel commands do while the relayer is stopped:
+//
+//	action=speedup|cancel&account=0x...&nonce=12[&gasprice=<wei>]
+func (this *PolyManager) TxHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		tx, err := this.replaceTx(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Infof("TxHandler - %s of %s nonce %s from %s: %s", r.Form.Get("action"), r.Form.Get("account"),
+			r.Form.Get("nonce"), r.RemoteAddr, tx.Hash().Hex())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&ReplacedTx{Hash: tx.Hash().Hex(), GasPrice: tx.GasPrice().String()})
+	})
+}
+
+func (this *PolyManager) replaceTx(r *http.Request) (*types.Transaction, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	var sender *EthSender
+	for _, v := range this.senders {
+		if strings.EqualFold(v.acc.Address.Hex(), r.Form.Get("account")) {
+			sender = v
+		}
+	}
+	if sender == nil {
+		return nil, fmt.Errorf("account %s is no sender of the relayer", r.Form.Get("account"))
+	}
+	nonce, err := strconv.ParseUint(r.Form.Get("nonce"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce %s", r.Form.Get("nonce"))
+	}
+	var gasPrice *big.Int
+	if v := r.Form.Get("gasprice"); v != "" {
+		var ok bool
+		if gasPrice, ok = new(big.Int).SetString(v, 10); !ok {
+			return nil, fmt.Errorf("invalid gas price %s", v)
+		}
+	}
+	switch r.Form.Get("action") {
+	case "speedup":
+		return sender.tracker.SpeedUp(nonce, gasPrice)
+	case "cancel":
+		return sender.tracker.Cancel(nonce, gasPrice)
+	}
+	return nil, fmt.Errorf("invalid action %s, speedup or cancel", r.Form.Get("action"))
+}
@@ -0,0 +1,113 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package manager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxHandler(t *testing.T) {
+	this := &PolyManager{senders: []*EthSender{newTestStates(bnb(1))[0].Sender}}
+	account := "&account=" + this.senders[0].acc.Address.Hex()
+	handler := this.TxHandler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tx", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	for body, msg := range map[string]string{
+		"action=speedup&nonce=1&account=0x02":        "no sender",
+		"action=speedup" + account:                   "invalid nonce",
+		"action=drop&nonce=1" + account:              "invalid action",
+		"action=cancel&nonce=1&gasprice=x" + account: "invalid gas price",
+	} {
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tx", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		assert.Contains(t, rr.Body.String(), msg, body)
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, v := range []struct {
+		token, method, host, origin, auth string
+		code                              int
+	}{
+		{"", http.MethodGet, "127.0.0.1:9091", "", "", http.StatusOK},
+		{"", http.MethodGet, "localhost:9091", "", "", http.StatusOK},
+		{"", http.MethodPost, "127.0.0.1:9091", "", "", http.StatusForbidden},
+		{"", http.MethodGet, "relayer.example.com:9091", "", "", http.StatusForbidden},
+		{"", http.MethodGet, "127.0.0.1:9091", "http://example.com", "", http.StatusForbidden},
+		{"secret", http.MethodPost, "10.0.0.5:9091", "", "Bearer secret", http.StatusOK},
+		{"secret", http.MethodGet, "127.0.0.1:9091", "", "", http.StatusUnauthorized},
+		{"secret", http.MethodPost, "10.0.0.5:9091", "", "Bearer other", http.StatusUnauthorized},
+		{"secret", http.MethodPost, "10.0.0.5:9091", "http://example.com", "Bearer secret", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(v.method, "/loglevel", nil)
+		req.Host = v.host
+		if v.origin != "" {
+			req.Header.Set("Origin", v.origin)
+		}
+		if v.auth != "" {
+			req.Header.Set("Authorization", v.auth)
+		}
+		rr := httptest.NewRecorder()
+		AdminAuth(v.token, ok).ServeHTTP(rr, req)
+		assert.Equal(t, v.code, rr.Code, "%+v", v)
+	}
+}
+
+func TestLifecycleHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	boltDB, err := db.NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer boltDB.Close()
+	record := &db.Lifecycle{ID: "bsc_to_poly/abcd/01", Direction: "bsc_to_poly", CCID: "01", SourceTx: "0xABCD"}
+	_, err = boltDB.AddLifecycleStage(record, &db.LifecycleStage{Stage: stageEventSeen, Height: 10}, "0x1234")
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	LifecycleHandler(boltDB).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/lookup?key=0x1234", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	records := make([]*db.Lifecycle, 0)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &records))
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "bsc_to_poly/abcd/01", records[0].ID)
+	}
+
+	rr = httptest.NewRecorder()
+	LifecycleHandler(boltDB).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/lookup", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,152 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package metrics keeps counters and gauges of the relayer and serves them
+// in the prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	counterType = "counter"
+	gaugeType   = "gauge"
+)
+
+var (
+	lock     sync.Mutex
+	registry = make(map[string]*Vec)
+)
+
+// Vec is a metric with one value per combination of label values
+type Vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *Vec {
+	return register(name, help, counterType, labels)
+}
+
+func NewGaugeVec(name, help string, labels ...string) *Vec {
+	return register(name, help, gaugeType, labels)
+}
+
+func register(name, help, kind string, labels []string) *Vec {
+	lock.Lock()
+	defer lock.Unlock()
+	if v, ok := registry[name]; ok {
+		return v
+	}
+	v := &Vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	registry[name] = v
+	return v
+}
+
+// key joins the label values, missing values are left empty
+func (this *Vec) key(values []string) string {
+	vals := make([]string, len(this.labels))
+	copy(vals, values)
+	return strings.Join(vals, "\x00")
+}
+
+func (this *Vec) Inc(values ...string) {
+	this.Add(1, values...)
+}
+
+func (this *Vec) Add(delta float64, values ...string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.values[this.key(values)] += delta
+}
+
+func (this *Vec) Set(val float64, values ...string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.values[this.key(values)] = val
+}
+
+func (this *Vec) Get(values ...string) float64 {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.values[this.key(values)]
+}
+
+func (this *Vec) write(w io.Writer) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", this.name, this.help, this.name, this.kind)
+	keys := make([]string, 0, len(this.values))
+	for k := range this.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val := strconv.FormatFloat(this.values[k], 'g', -1, 64)
+		if len(this.labels) == 0 {
+			fmt.Fprintf(w, "%s %s\n", this.name, val)
+			continue
+		}
+		pairs := make([]string, len(this.labels))
+		for i, v := range strings.Split(k, "\x00") {
+			pairs[i] = fmt.Sprintf("%s=%q", this.labels[i], v)
+		}
+		fmt.Fprintf(w, "%s{%s} %s\n", this.name, strings.Join(pairs, ","), val)
+	}
+}
+
+// WriteAll writes every registered metric sorted by name
+func WriteAll(w io.Writer) {
+	lock.Lock()
+	vecs := make([]*Vec, 0, len(registry))
+	for _, v := range registry {
+		vecs = append(vecs, v)
+	}
+	lock.Unlock()
+	sort.Slice(vecs, func(i, j int) bool {
+		return vecs[i].name < vecs[j].name
+	})
+	for _, v := range vecs {
+		v.write(w)
+	}
+}
+
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteAll(w)
+	})
+}
+
+// Serve exposes the metrics on http://addr/metrics, it blocks like http.ListenAndServe
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,40 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAll(t *testing.T) {
+	c := NewCounterVec("test_refused_total", "refused relays", "contract")
+	c.Inc("0xabc")
+	c.Add(2, "0xabc")
+	g := NewGaugeVec("test_height", "synced height")
+	g.Set(100)
+	assert.Equal(t, c, NewCounterVec("test_refused_total", "refused relays", "contract"))
+	assert.Equal(t, float64(3), c.Get("0xabc"))
+
+	buf := new(bytes.Buffer)
+	WriteAll(buf)
+	assert.Equal(t, "# HELP test_height synced height\n# TYPE test_height gauge\ntest_height 100\n"+
+		"# HELP test_refused_total refused relays\n# TYPE test_refused_total counter\ntest_refused_total{contract=\"0xabc\"} 3\n",
+		buf.String())
+}
@@ -0,0 +1,119 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package signermock is a stand-in for an external signer, serving the
+// account_ API of clef and the poly_ API of the relayer from keys held in
+// process. It is meant for tests.
+package signermock
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/polynetwork/bsc-relayer/tools"
+	sdk "github.com/polynetwork/poly-go-sdk"
+)
+
+type accountService struct {
+	signer tools.Signer
+}
+
+func (this *accountService) List() []common.Address {
+	accArr := this.signer.GetAccounts()
+	addrs := make([]common.Address, len(accArr))
+	for i, v := range accArr {
+		addrs[i] = v.Address
+	}
+	return addrs
+}
+
+func (this *accountService) SignTransaction(args tools.SignTxArgs) (*tools.SignTxResponse, error) {
+	for _, acc := range this.signer.GetAccounts() {
+		if acc.Address != args.From.Address() {
+			continue
+		}
+		tx, err := this.signer.SignTransaction(args.Transaction(), acc)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			return nil, err
+		}
+		return &tools.SignTxResponse{Raw: raw, Tx: tx}, nil
+	}
+	return nil, fmt.Errorf("unknown account %s", args.From.Address().Hex())
+}
+
+type polyService struct {
+	signer sdk.Signer
+}
+
+func (this *polyService) PublicKey() hexutil.Bytes {
+	return keypair.SerializePublicKey(this.signer.GetPublicKey())
+}
+
+func (this *polyService) Sign(data hexutil.Bytes) (hexutil.Bytes, error) {
+	return this.signer.Sign(data)
+}
+
+// Server signs bsc transactions with signer and poly transactions with
+// polySigner, either may be nil
+type Server struct {
+	rpc      *rpc.Server
+	listener net.Listener
+}
+
+func NewServer(signer tools.Signer, polySigner sdk.Signer) (*Server, error) {
+	server := rpc.NewServer()
+	if signer != nil {
+		if err := server.RegisterName("account", &accountService{signer: signer}); err != nil {
+			return nil, err
+		}
+	}
+	if polySigner != nil {
+		if err := server.RegisterName("poly", &polyService{signer: polySigner}); err != nil {
+			return nil, err
+		}
+	}
+	return &Server{rpc: server}, nil
+}
+
+// Start listens on addr, use 127.0.0.1:0 for a free port, and returns the URL to configure
+// as RemoteSigner.URL
+func (this *Server) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	this.listener = listener
+	go http.Serve(listener, this.rpc)
+	return "http://" + listener.Addr().String(), nil
+}
+
+func (this *Server) Stop() {
+	if this.listener != nil {
+		this.listener.Close()
+	}
+	this.rpc.Stop()
+}
@@ -0,0 +1,104 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package signermock
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/tools"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+// keySigner signs with a raw key, without the scrypt cost of a keystore
+type keySigner struct {
+	key     *ecdsa.PrivateKey
+	chainId *big.Int
+}
+
+func (this *keySigner) GetAccounts() []accounts.Account {
+	return []accounts.Account{{Address: crypto.PubkeyToAddress(this.key.PublicKey)}}
+}
+
+func (this *keySigner) SignTransaction(tx *types.Transaction, acc accounts.Account) (*types.Transaction, error) {
+	return types.SignTx(tx, types.NewEIP155Signer(this.chainId), this.key)
+}
+
+func (this *keySigner) GetChainId() uint64 {
+	return this.chainId.Uint64()
+}
+
+func start(t *testing.T, signer tools.Signer, polySigner sdk.Signer) (*Server, *config.RemoteSignerConfig) {
+	server, err := NewServer(signer, polySigner)
+	assert.NoError(t, err)
+	url, err := server.Start("127.0.0.1:0")
+	assert.NoError(t, err)
+	return server, &config.RemoteSignerConfig{URL: url, Timeout: 5}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chainId := big.NewInt(97)
+	server, cfg := start(t, &keySigner{key: key, chainId: chainId}, nil)
+	defer server.Stop()
+
+	signer, err := tools.NewRemoteSigner(cfg, chainId)
+	assert.NoError(t, err)
+	accArr := signer.GetAccounts()
+	assert.Equal(t, 1, len(accArr))
+	acc := accArr[0]
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), acc.Address)
+
+	tx := types.NewTransaction(3, common.HexToAddress("0x1234"), big.NewInt(5), 21000, big.NewInt(10e9), []byte{1, 2})
+	signed, err := signer.SignTransaction(tx, acc)
+	assert.NoError(t, err)
+	from, err := types.Sender(types.NewEIP155Signer(chainId), signed)
+	assert.NoError(t, err)
+	assert.Equal(t, acc.Address, from)
+	assert.Equal(t, tx.Nonce(), signed.Nonce())
+	assert.Equal(t, tx.Data(), signed.Data())
+
+	_, err = signer.SignTransaction(tx, accounts.Account{Address: common.HexToAddress("0x99")})
+	assert.Error(t, err)
+
+	// a signer on another chain is refused
+	other, err := tools.NewRemoteSigner(cfg, big.NewInt(56))
+	assert.NoError(t, err)
+	_, err = other.SignTransaction(tx, acc)
+	assert.Error(t, err)
+}
+
+func TestRemotePolySigner(t *testing.T) {
+	acc := sdk.NewAccount()
+	server, cfg := start(t, nil, acc)
+	defer server.Stop()
+
+	signer, err := tools.NewRemotePolySigner(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, acc.Address, signer.Address())
+
+	sig, err := signer.Sign([]byte("poly tx hash"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, sig)
+}
@@ -17,6 +17,7 @@
 package tools
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 	"strings"
@@ -52,6 +53,22 @@ func NewEthKeyStore(sigConfig *config.BSCConfig, chainId *big.Int) *EthKeyStore
 	return service
 }
 
+// OpenEthKeyStore opens the keystore dir, unlike NewEthKeyStore it may hold no account yet
+func OpenEthKeyStore(dir string, chainId *big.Int) *EthKeyStore {
+	return &EthKeyStore{
+		ks:      keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP),
+		chainId: chainId,
+	}
+}
+
+func (this *EthKeyStore) NewAccount(pwd string) (accounts.Account, error) {
+	return this.ks.NewAccount(pwd)
+}
+
+func (this *EthKeyStore) ImportKey(key *ecdsa.PrivateKey, pwd string) (accounts.Account, error) {
+	return this.ks.ImportECDSA(key, pwd)
+}
+
 func (this *EthKeyStore) UnlockKeys(sigConfig *config.BSCConfig) error {
 	for _, v := range this.GetAccounts() {
 		err := this.ks.Unlock(v, sigConfig.KeyStorePwdSet[strings.ToLower(v.Address.String())])
@@ -62,6 +79,10 @@ func (this *EthKeyStore) UnlockKeys(sigConfig *config.BSCConfig) error {
 	return nil
 }
 
+func (this *EthKeyStore) UnlockAccount(acc accounts.Account, pwd string) error {
+	return this.ks.Unlock(acc, pwd)
+}
+
 func (this *EthKeyStore) SignTransaction(tx *types.Transaction, acc accounts.Account) (*types.Transaction, error) {
 	tx, err := this.ks.SignTx(acc, tx, this.chainId)
 	if err != nil {
@@ -85,3 +106,19 @@ func (this *EthKeyStore) TestPwd(acc accounts.Account, pwd string) error {
 func (this *EthKeyStore) GetChainId() uint64 {
 	return this.chainId.Uint64()
 }
+
+// NewSingleAccountKeyStore opens the account addr of the keystore dir and
+// unlocks it, for accounts like the treasury which must not relay
+func NewSingleAccountKeyStore(dir, addr, pwd string, chainId *big.Int) (*EthKeyStore, accounts.Account, error) {
+	ks := keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+	for _, v := range ks.Accounts() {
+		if !strings.EqualFold(v.Address.Hex(), addr) {
+			continue
+		}
+		if err := ks.Unlock(v, pwd); err != nil {
+			return nil, accounts.Account{}, fmt.Errorf("failed to unlock eth acc %s: %v", v.Address.String(), err)
+		}
+		return &EthKeyStore{ks: ks, chainId: chainId}, v, nil
+	}
+	return nil, accounts.Account{}, fmt.Errorf("account %s not found in %s", addr, dir)
+}
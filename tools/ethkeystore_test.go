@@ -17,21 +17,47 @@
 package tools
 
 import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/polynetwork/bsc-relayer/config"
-	"testing"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestETHSigner_SignTransaction(t *testing.T) {
-	cfg := config.NewServiceConfig("./config-debug.json")
-	ethsigner := NewEthKeyStore(cfg.ETHConfig)
-	tx := &types.Transaction{}
-	tx, err := ethsigner.SignTransaction(tx)
-	if err != nil {
-		t.Fatal(err)
+	dir, err := ioutil.TempDir("", "keystore")
+	if !assert.NoError(t, err) {
+		return
 	}
-	v, r, s := tx.RawSignatureValues()
-	if v.BitLen()+r.BitLen()+s.BitLen() <= 0 {
-		t.Fatal("failed to sign")
+	defer os.RemoveAll(dir)
+	acc, err := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP).NewAccount("pwd")
+	if !assert.NoError(t, err) {
+		return
 	}
+
+	cfg := &config.BSCConfig{
+		KeyStorePath:   dir,
+		KeyStorePwdSet: map[string]string{strings.ToLower(acc.Address.Hex()): "pwd"},
+	}
+	ethsigner := NewEthKeyStore(cfg, big.NewInt(56))
+	if !assert.NoError(t, ethsigner.UnlockKeys(cfg)) {
+		return
+	}
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	tx, err = ethsigner.SignTransaction(tx, acc)
+	if !assert.NoError(t, err) {
+		return
+	}
+	v, r, s := tx.RawSignatureValues()
+	assert.True(t, v.BitLen()+r.BitLen()+s.BitLen() > 0)
+	from, err := types.Sender(types.NewEIP155Signer(big.NewInt(56)), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, acc.Address, from)
+	assert.Equal(t, uint64(56), ethsigner.GetChainId())
 }
@@ -0,0 +1,234 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/polynetwork/bsc-relayer/config"
+)
+
+const (
+	GasStrategyNode       = "node"
+	GasStrategyPercentile = "percentile"
+	GasStrategyFixed      = "fixed"
+	GasStrategyEIP1559    = "eip1559"
+
+	defaultGasMultiplier    = 1.2
+	defaultPercentileBlocks = 20
+	defaultPercentile       = 60
+)
+
+var ErrGasPriceTooHigh = errors.New("gas price is above the configured max price")
+
+// GasOracle suggests the gas price for transactions sent to BSC
+type GasOracle interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// NodeGasOracle asks the node for its suggestion
+type NodeGasOracle struct {
+	client *ethclient.Client
+}
+
+func (this *NodeGasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return this.client.SuggestGasPrice(ctx)
+}
+
+// PercentileGasOracle takes a percentile of the prices paid in recent blocks,
+// the result is cached until a new block arrives
+type PercentileGasOracle struct {
+	client     *ethclient.Client
+	blocks     uint64
+	percentile int
+
+	lock     sync.Mutex
+	lastHead *big.Int
+	lastRes  *big.Int
+}
+
+func (this *PercentileGasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	head, err := this.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if this.lastHead != nil && this.lastHead.Cmp(head.Number) == 0 {
+		return new(big.Int).Set(this.lastRes), nil
+	}
+	prices := make([]*big.Int, 0)
+	for i := uint64(0); i < this.blocks && i <= head.Number.Uint64(); i++ {
+		blk, err := this.client.BlockByNumber(ctx, new(big.Int).Sub(head.Number, new(big.Int).SetUint64(i)))
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range blk.Transactions() {
+			// system transactions on BSC are free
+			if tx.GasPrice().Sign() > 0 {
+				prices = append(prices, tx.GasPrice())
+			}
+		}
+	}
+	if len(prices) == 0 {
+		return this.client.SuggestGasPrice(ctx)
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Cmp(prices[j]) < 0
+	})
+	this.lastHead = head.Number
+	this.lastRes = prices[(len(prices)-1)*this.percentile/100]
+	return new(big.Int).Set(this.lastRes), nil
+}
+
+// FixedGasOracle always returns the configured price
+type FixedGasOracle struct {
+	price *big.Int
+}
+
+func (this *FixedGasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(this.price), nil
+}
+
+// EIP1559GasOracle prices transactions from the base fee and priority fee
+// of chains running EIP-1559. The transactions we sign are still legacy ones,
+// so the price is base fee plus tip, bounded by the configured caps.
+type EIP1559GasOracle struct {
+	url        func() string
+	restClient *RestClient
+	maxFee     *big.Int
+	maxTip     *big.Int
+}
+
+func (this *EIP1559GasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	baseFee, err := GetBaseFee(this.url(), this.restClient)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := GetMaxPriorityFee(this.url(), this.restClient)
+	if err != nil {
+		return nil, err
+	}
+	if this.maxTip != nil && tip.Cmp(this.maxTip) > 0 {
+		tip = new(big.Int).Set(this.maxTip)
+	}
+	if this.maxFee != nil && baseFee.Cmp(this.maxFee) >= 0 {
+		return nil, fmt.Errorf("%w: base fee %s, fee cap %s", ErrGasPriceTooHigh, baseFee.String(), this.maxFee.String())
+	}
+	// leave room for the base fee to rise by one full block
+	price := new(big.Int).Quo(new(big.Int).Mul(baseFee, big.NewInt(9)), big.NewInt(8))
+	price.Add(price, tip)
+	if this.maxFee != nil && price.Cmp(this.maxFee) > 0 {
+		price.Set(this.maxFee)
+	}
+	return price, nil
+}
+
+// BoundedGasOracle applies the multiplier, floor, ceiling and max price
+// from the config on top of a strategy
+type BoundedGasOracle struct {
+	oracle     GasOracle
+	multiplier float64
+	floor      *big.Int
+	ceiling    *big.Int
+	max        *big.Int
+}
+
+func NewGasOracle(cfg *config.GasPriceConfig, client *ethclient.Client, url func() string) (*BoundedGasOracle, error) {
+	if cfg == nil {
+		cfg = &config.GasPriceConfig{}
+	}
+	var oracle GasOracle
+	switch cfg.Strategy {
+	case "", GasStrategyNode:
+		oracle = &NodeGasOracle{client: client}
+	case GasStrategyPercentile:
+		o := &PercentileGasOracle{client: client, blocks: cfg.PercentileBlocks, percentile: cfg.Percentile}
+		if o.blocks == 0 {
+			o.blocks = defaultPercentileBlocks
+		}
+		if o.percentile <= 0 || o.percentile > 100 {
+			o.percentile = defaultPercentile
+		}
+		oracle = o
+	case GasStrategyFixed:
+		if cfg.FixedPrice == 0 {
+			return nil, fmt.Errorf("NewGasOracle - FixedPrice is required by the fixed strategy")
+		}
+		oracle = &FixedGasOracle{price: new(big.Int).SetUint64(cfg.FixedPrice)}
+	case GasStrategyEIP1559:
+		oracle = &EIP1559GasOracle{
+			url:        url,
+			restClient: NewRestClient(),
+			maxFee:     uint64ToBig(cfg.MaxFeePerGas),
+			maxTip:     uint64ToBig(cfg.MaxPriorityFeePerGas),
+		}
+	default:
+		return nil, fmt.Errorf("NewGasOracle - unknown gas price strategy %s", cfg.Strategy)
+	}
+
+	multiplier := cfg.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultGasMultiplier
+	}
+	return &BoundedGasOracle{
+		oracle:     oracle,
+		multiplier: multiplier,
+		floor:      uint64ToBig(cfg.FloorPrice),
+		ceiling:    uint64ToBig(cfg.CeilingPrice),
+		max:        uint64ToBig(cfg.MaxPrice),
+	}, nil
+}
+
+// SuggestGasPrice returns ErrGasPriceTooHigh together with the price when
+// the price is above the max price, callers should wait instead of sending
+func (this *BoundedGasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	price, err := this.oracle.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	price, _ = new(big.Float).Mul(new(big.Float).SetInt(price), big.NewFloat(this.multiplier)).Int(nil)
+	if this.floor != nil && price.Cmp(this.floor) < 0 {
+		price.Set(this.floor)
+	}
+	if this.ceiling != nil && price.Cmp(this.ceiling) > 0 {
+		price.Set(this.ceiling)
+	}
+	if this.max != nil && price.Cmp(this.max) > 0 {
+		return price, ErrGasPriceTooHigh
+	}
+	return price, nil
+}
+
+// MaxPrice returns nil if there is no max price
+func (this *BoundedGasOracle) MaxPrice() *big.Int {
+	return this.max
+}
+
+func uint64ToBig(v uint64) *big.Int {
+	if v == 0 {
+		return nil
+	}
+	return new(big.Int).SetUint64(v)
+}
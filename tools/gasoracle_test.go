@@ -0,0 +1,53 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedGasOracle(t *testing.T) {
+	oracle, err := NewGasOracle(&config.GasPriceConfig{
+		Strategy:     GasStrategyFixed,
+		FixedPrice:   10,
+		Multiplier:   1.5,
+		FloorPrice:   20,
+		CeilingPrice: 30,
+	}, nil, nil)
+	assert.NoError(t, err)
+	price, err := oracle.SuggestGasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(20), price)
+
+	oracle.oracle = &FixedGasOracle{price: big.NewInt(100)}
+	price, err = oracle.SuggestGasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(30), price)
+
+	oracle.max = big.NewInt(25)
+	price, err = oracle.SuggestGasPrice(context.Background())
+	assert.Equal(t, ErrGasPriceTooHigh, err)
+	assert.Equal(t, big.NewInt(30), price)
+
+	_, err = NewGasOracle(&config.GasPriceConfig{Strategy: "unknown"}, nil, nil)
+	assert.Error(t, err)
+}
@@ -18,73 +18,89 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"sync"
-	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/polynetwork/bsc-relayer/db"
 	"github.com/polynetwork/bsc-relayer/log"
 )
 
-const clear_nonce_interval = 10 * time.Minute
+// gas of a plain value transfer
+const TransferGasLimit = 21000
 
+// NonceManager hands out nonces for relayer accounts. It is seeded from the
+// pending nonce of the node and keeps its state in BoltDB, so that a restart
+// with transactions still in the pool neither reuses nor skips nonces.
 type NonceManager struct {
-	addressNonce  map[common.Address]uint64
-	returnedNonce map[common.Address]SortedNonceArr
-	ethClient     *ethclient.Client
-	lock          sync.Mutex
+	addressNonce   map[common.Address]uint64
+	confirmedNonce map[common.Address]uint64
+	returnedNonce  map[common.Address]SortedNonceArr
+	ethClient      *ethclient.Client
+	db             *db.BoltDB
+	lock           sync.Mutex
 }
 
-func NewNonceManager(ethClient *ethclient.Client) *NonceManager {
+func NewNonceManager(ethClient *ethclient.Client, boltDB *db.BoltDB) *NonceManager {
 	nonceManager := &NonceManager{
-		addressNonce:  make(map[common.Address]uint64),
-		ethClient:     ethClient,
-		returnedNonce: make(map[common.Address]SortedNonceArr),
+		addressNonce:   make(map[common.Address]uint64),
+		confirmedNonce: make(map[common.Address]uint64),
+		ethClient:      ethClient,
+		db:             boltDB,
+		returnedNonce:  make(map[common.Address]SortedNonceArr),
 	}
-	//go nonceManager.clearNonce()
 	return nonceManager
 }
 
 // return account nonce, and than nonce++
-func (this *NonceManager) GetAddressNonce(address common.Address) uint64 {
+func (this *NonceManager) GetAddressNonce(address common.Address) (uint64, error) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
 
+	if err := this.load(address); err != nil {
+		return 0, err
+	}
 	if this.returnedNonce[address].Len() > 0 {
 		nonce := this.returnedNonce[address][0]
 		this.returnedNonce[address] = this.returnedNonce[address][1:]
-		return nonce
+		this.save(address)
+		return nonce, nil
 	}
 
 	// return a new point
-	nonce, ok := this.addressNonce[address]
-	if !ok {
-		// get nonce from eth network
-		uintNonce, err := this.ethClient.NonceAt(context.Background(), address, nil)
-		if err != nil {
-			log.Errorf("GetAddressNonce: cannot get account %s nonce, err: %s, set it to nil!",
-				address, err)
-		}
-		this.addressNonce[address] = uintNonce
-		nonce = uintNonce
-	}
+	nonce := this.addressNonce[address]
 	// increase record
 	this.addressNonce[address]++
-	return nonce
+	this.save(address)
+	return nonce, nil
 }
 
+// ReturnNonce gives back a nonce which was not sent. If later nonces are
+// already issued it is a gap, and is handed out again or filled by TakeGaps.
 func (this *NonceManager) ReturnNonce(addr common.Address, nonce uint64) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
 
-	arr, ok := this.returnedNonce[addr]
-	if !ok {
-		arr = make([]uint64, 0)
+	if _, ok := this.addressNonce[addr]; !ok || nonce >= this.addressNonce[addr] {
+		return
+	}
+	arr := this.returnedNonce[addr]
+	for _, v := range arr {
+		if v == nonce {
+			return
+		}
 	}
 	arr = append(arr, nonce)
 	sort.Sort(arr)
+	// nonces at the top are no gap, just issue them again
+	for len(arr) > 0 && arr[len(arr)-1] == this.addressNonce[addr]-1 {
+		arr = arr[:len(arr)-1]
+		this.addressNonce[addr]--
+	}
 	this.returnedNonce[addr] = arr
+	this.save(addr)
 }
 
 func (this *NonceManager) DecreaseAddressNonce(address common.Address) {
@@ -94,19 +110,119 @@ func (this *NonceManager) DecreaseAddressNonce(address common.Address) {
 	nonce, ok := this.addressNonce[address]
 	if ok && nonce > 0 {
 		this.addressNonce[address]--
+		this.save(address)
 	}
 }
 
-// clear nonce per
-func (this *NonceManager) clearNonce() {
-	for {
-		<-time.After(clear_nonce_interval)
-		this.lock.Lock()
-		for addr, _ := range this.addressNonce {
-			delete(this.addressNonce, addr)
+// ConfirmNonce records that the transaction with the nonce is mined
+func (this *NonceManager) ConfirmNonce(address common.Address, nonce uint64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if nonce+1 <= this.confirmedNonce[address] {
+		return
+	}
+	this.confirmedNonce[address] = nonce + 1
+	this.save(address)
+}
+
+// SyncAddressNonce moves the next nonce forward to the pending nonce of the
+// node, used when a send fails with "nonce too low"
+func (this *NonceManager) SyncAddressNonce(address common.Address) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if err := this.load(address); err != nil {
+		return err
+	}
+	pending, err := this.ethClient.PendingNonceAt(context.Background(), address)
+	if err != nil {
+		return fmt.Errorf("SyncAddressNonce - cannot get pending nonce of %s: %v", address.Hex(), err)
+	}
+	if pending > this.addressNonce[address] {
+		log.Warnf("SyncAddressNonce - account %s nonce moves from %d to pending nonce %d",
+			address.Hex(), this.addressNonce[address], pending)
+		this.addressNonce[address] = pending
+	}
+	this.dropReturnedBelow(address, pending)
+	this.save(address)
+	return nil
+}
+
+// TakeGaps returns the nonces which were given back by failed sends and not
+// reused yet. The caller owns them and should fill them or return them.
+func (this *NonceManager) TakeGaps(address common.Address) ([]uint64, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if err := this.load(address); err != nil {
+		return nil, err
+	}
+	mined, err := this.ethClient.NonceAt(context.Background(), address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("TakeGaps - cannot get nonce of %s: %v", address.Hex(), err)
+	}
+	if mined > this.confirmedNonce[address] {
+		this.confirmedNonce[address] = mined
+	}
+	this.dropReturnedBelow(address, mined)
+	gaps := this.returnedNonce[address]
+	delete(this.returnedNonce, address)
+	this.save(address)
+	return gaps, nil
+}
+
+// load seeds the account from the pending nonce of the node and the nonces in DB.
+// The gaps given back before a restart are kept, the transactions queued above
+// them are mined once fillNonceGaps fills them.
+func (this *NonceManager) load(address common.Address) error {
+	if _, ok := this.addressNonce[address]; ok {
+		return nil
+	}
+	pending, err := this.ethClient.PendingNonceAt(context.Background(), address)
+	if err != nil {
+		return fmt.Errorf("GetAddressNonce - cannot get pending nonce of %s: %v", address.Hex(), err)
+	}
+	next, returned := pending, make(SortedNonceArr, 0)
+	if this.db != nil {
+		stored, confirmed, storedReturned, err := this.db.GetNonce(address.Bytes())
+		if err != nil {
+			return fmt.Errorf("GetAddressNonce - cannot load nonce of %s: %v", address.Hex(), err)
+		}
+		if stored > pending {
+			next = stored
+			for _, v := range storedReturned {
+				if v >= pending && v < stored {
+					returned = append(returned, v)
+				}
+			}
+			sort.Sort(returned)
+			log.Warnf("GetAddressNonce - account %s has issued nonce %d but pending nonce is %d, gaps to fill: %v",
+				address.Hex(), stored, pending, []uint64(returned))
 		}
-		this.lock.Unlock()
-		//log.Infof("clearNonce: clear all cache nonce")
+		this.confirmedNonce[address] = confirmed
+	}
+	this.addressNonce[address] = next
+	this.returnedNonce[address] = returned
+	log.Infof("GetAddressNonce - account %s starts from nonce %d", address.Hex(), next)
+	return nil
+}
+
+func (this *NonceManager) dropReturnedBelow(address common.Address, nonce uint64) {
+	arr := this.returnedNonce[address]
+	for len(arr) > 0 && arr[0] < nonce {
+		arr = arr[1:]
+	}
+	this.returnedNonce[address] = arr
+}
+
+func (this *NonceManager) save(address common.Address) {
+	if this.db == nil {
+		return
+	}
+	err := this.db.PutNonce(address.Bytes(), this.addressNonce[address], this.confirmedNonce[address], this.returnedNonce[address])
+	if err != nil {
+		log.Errorf("NonceManager - failed to save nonce of %s: %v", address.Hex(), err)
 	}
 }
 
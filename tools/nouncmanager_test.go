@@ -0,0 +1,84 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonceManagerRestart(t *testing.T) {
+	// the node knows nonces up to 5, the relayer issued up to 8 before the restart
+	// and 6 was given back by a failed send
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ID json.RawMessage `json:"id"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x5"}`, req.ID)
+	}))
+	defer srv.Close()
+	client, err := ethclient.Dial(srv.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	dir, err := ioutil.TempDir("", "nonce")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	boltDB, err := db.NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer boltDB.Close()
+	addr := common.HexToAddress("0x01")
+	assert.NoError(t, boltDB.PutNonce(addr.Bytes(), 8, 3, []uint64{4, 6}))
+
+	// the gap above the pending nonce is kept and issued first, 4 is mined
+	nm := NewNonceManager(client, boltDB)
+	for _, expected := range []uint64{6, 8, 9} {
+		nonce, err := nm.GetAddressNonce(addr)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, nonce)
+	}
+	next, confirmed, returned, err := boltDB.GetNonce(addr.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), next)
+	assert.Equal(t, uint64(3), confirmed)
+	assert.Empty(t, returned)
+
+	// or filled by fillNonceGaps after another restart
+	assert.NoError(t, boltDB.PutNonce(addr.Bytes(), 8, 3, []uint64{4, 6}))
+	nm = NewNonceManager(client, boltDB)
+	gaps, err := nm.TakeGaps(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{6}, gaps)
+	nonce, err := nm.GetAddressNonce(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), nonce)
+}
@@ -0,0 +1,176 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/log"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	pcommon "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/native/service/governance/relayer_manager"
+	"github.com/polynetwork/poly/native/service/governance/side_chain_manager"
+	autils "github.com/polynetwork/poly/native/service/utils"
+)
+
+// NewPolySigner is the remote signer if configured, otherwise the default
+// account of WalletFile. A missing wallet or account is only created with
+// AllowNewWallet, since a new account is no registered relayer.
+func NewPolySigner(cfg *config.PolyConfig, polySdk *sdk.PolySdk) (sdk.Signer, pcommon.Address, error) {
+	if cfg.RemoteSigner != nil && cfg.RemoteSigner.URL != "" {
+		signer, err := NewRemotePolySigner(cfg.RemoteSigner)
+		if err != nil {
+			return nil, pcommon.ADDRESS_EMPTY, err
+		}
+		return signer, signer.Address(), nil
+	}
+
+	var wallet *sdk.Wallet
+	var err error
+	if !pcommon.FileExisted(cfg.WalletFile) {
+		if !cfg.AllowNewWallet {
+			return nil, pcommon.ADDRESS_EMPTY, fmt.Errorf("poly wallet %s not found, create it with poly-wallet create "+
+				"and register it with register-relayer", cfg.WalletFile)
+		}
+		if wallet, err = polySdk.CreateWallet(cfg.WalletFile); err != nil {
+			return nil, pcommon.ADDRESS_EMPTY, err
+		}
+	} else if wallet, err = polySdk.OpenWallet(cfg.WalletFile); err != nil {
+		return nil, pcommon.ADDRESS_EMPTY, fmt.Errorf("poly wallet %s open error: %v", cfg.WalletFile, err)
+	}
+
+	if _, err = wallet.GetDefaultAccountData(); err != nil {
+		if !cfg.AllowNewWallet {
+			return nil, pcommon.ADDRESS_EMPTY, fmt.Errorf("poly wallet %s has no default account, create it with poly-wallet create",
+				cfg.WalletFile)
+		}
+		signer, err := wallet.NewDefaultSettingAccount([]byte(cfg.WalletPwd))
+		if err != nil {
+			return nil, pcommon.ADDRESS_EMPTY, err
+		}
+		if err = wallet.Save(); err != nil {
+			return nil, pcommon.ADDRESS_EMPTY, err
+		}
+		log.Warnf("NewPolySigner - created poly account %s, it relays nothing until registered with register-relayer",
+			signer.Address.ToBase58())
+		return signer, signer.Address, nil
+	}
+	signer, err := wallet.GetDefaultAccount([]byte(cfg.WalletPwd))
+	if err != nil {
+		return nil, pcommon.ADDRESS_EMPTY, fmt.Errorf("wrong password of poly wallet %s: %v", cfg.WalletFile, err)
+	}
+	return signer, signer.Address, nil
+}
+
+// NewPolyProofSigners opens the ProofAccounts of WalletFile, each with its
+// password from ProofAccountPwdSet or WalletPwd
+func NewPolyProofSigners(cfg *config.PolyConfig, polySdk *sdk.PolySdk) ([]*sdk.Account, error) {
+	if len(cfg.ProofAccounts) == 0 {
+		return nil, nil
+	}
+	if cfg.RemoteSigner != nil && cfg.RemoteSigner.URL != "" {
+		return nil, fmt.Errorf("ProofAccounts are accounts of WalletFile and can not be used with a remote signer")
+	}
+	wallet, err := polySdk.OpenWallet(cfg.WalletFile)
+	if err != nil {
+		return nil, fmt.Errorf("poly wallet %s open error: %v", cfg.WalletFile, err)
+	}
+	signers := make([]*sdk.Account, 0, len(cfg.ProofAccounts))
+	seen := make(map[string]bool)
+	for _, addr := range cfg.ProofAccounts {
+		if seen[addr] {
+			return nil, fmt.Errorf("proof account %s is listed twice", addr)
+		}
+		seen[addr] = true
+		pwd, ok := cfg.ProofAccountPwdSet[addr]
+		if !ok {
+			pwd = cfg.WalletPwd
+		}
+		signer, err := wallet.GetAccountByAddress(addr, []byte(pwd))
+		if err != nil {
+			return nil, fmt.Errorf("proof account %s of poly wallet %s: %v", addr, cfg.WalletFile, err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// IsPolyRelayer tells whether the address is an approved relayer of poly
+func IsPolyRelayer(polySdk *sdk.PolySdk, addr pcommon.Address) (bool, error) {
+	raw, err := polySdk.GetStorage(autils.RelayerManagerContractAddress.ToHexString(),
+		append([]byte(relayer_manager.RELAYER), addr[:]...))
+	if err != nil {
+		return false, err
+	}
+	return len(raw) > 0, nil
+}
+
+// GetPolySideChain returns the registration of the chain on poly, nil if the chain is not registered
+func GetPolySideChain(polySdk *sdk.PolySdk, chainId uint64) (*side_chain_manager.SideChain, error) {
+	raw, err := polySdk.GetStorage(autils.SideChainManagerContractAddress.ToHexString(),
+		append([]byte(side_chain_manager.SIDE_CHAIN), autils.GetUint64Bytes(chainId)...))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	sideChain := new(side_chain_manager.SideChain)
+	if err = sideChain.Deserialization(pcommon.NewZeroCopySource(raw)); err != nil {
+		return nil, fmt.Errorf("GetPolySideChain - deserialize side chain %d error: %v", chainId, err)
+	}
+	return sideChain, nil
+}
+
+// RegisterPolyRelayer applies for the addresses to be relayers, the signer
+// pays for it, and returns the apply ID the consensus nodes approve
+func RegisterPolyRelayer(polySdk *sdk.PolySdk, signer sdk.Signer, signerAddr pcommon.Address, addrs []pcommon.Address) (uint64, error) {
+	tx, err := polySdk.Native.Rm.NewRegisterRelayerTransaction(addrs, signerAddr)
+	if err != nil {
+		return 0, err
+	}
+	if err = polySdk.SignToTransaction(tx, signer); err != nil {
+		return 0, err
+	}
+	hash, err := polySdk.SendTransaction(tx)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < 60; i++ {
+		time.Sleep(time.Second)
+		event, err := polySdk.GetSmartContractEvent(hash.ToHexString())
+		if err != nil || event == nil {
+			continue
+		}
+		if event.State != 1 {
+			return 0, fmt.Errorf("registration %s failed on poly", hash.ToHexString())
+		}
+		for _, notify := range event.Notify {
+			states, ok := notify.States.([]interface{})
+			if !ok || len(states) < 2 || states[0] != "putRelayerApply" {
+				continue
+			}
+			if id, ok := states[1].(float64); ok {
+				return uint64(id), nil
+			}
+		}
+		return 0, fmt.Errorf("no apply ID in the events of registration %s", hash.ToHexString())
+	}
+	return 0, fmt.Errorf("registration %s is not executed after a minute", hash.ToHexString())
+}
@@ -0,0 +1,178 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/polynetwork/bsc-relayer/config"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	pcommon "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/native/service/governance/relayer_manager"
+	"github.com/polynetwork/poly/native/service/governance/side_chain_manager"
+	autils "github.com/polynetwork/poly/native/service/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// newPolyStorageStub serves getstorage from storage, keyed by contract and
+// hex key, an unknown key is empty storage and a nil value an RPC error
+func newPolyStorageStub(storage map[string][]byte) (*httptest.Server, *sdk.PolySdk) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ID     string   `json:"id"`
+			Params []string `json:"params"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Params) != 2 {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"%s","error":42002,"desc":"INVALID PARAMS"}`, req.ID)
+			return
+		}
+		value, ok := storage[req.Params[0]+req.Params[1]]
+		if ok && value == nil {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"%s","error":42001,"desc":"SESSION EXPIRED"}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"%s","error":0,"desc":"SUCCESS","result":"%s"}`, req.ID, hex.EncodeToString(value))
+	}))
+	polySdk := sdk.NewPolySdk()
+	polySdk.NewRpcClient().SetAddress(srv.URL)
+	return srv, polySdk
+}
+
+func relayerStorageKey(addr pcommon.Address) string {
+	return autils.RelayerManagerContractAddress.ToHexString() +
+		hex.EncodeToString(append([]byte(relayer_manager.RELAYER), addr[:]...))
+}
+
+func sideChainStorageKey(chainId uint64) string {
+	return autils.SideChainManagerContractAddress.ToHexString() +
+		hex.EncodeToString(append([]byte(side_chain_manager.SIDE_CHAIN), autils.GetUint64Bytes(chainId)...))
+}
+
+func TestIsPolyRelayer(t *testing.T) {
+	approved, unknown, failing := pcommon.Address{1}, pcommon.Address{2}, pcommon.Address{3}
+	srv, polySdk := newPolyStorageStub(map[string][]byte{
+		relayerStorageKey(approved): {1},
+		relayerStorageKey(failing):  nil,
+	})
+	defer srv.Close()
+
+	for _, c := range []struct {
+		addr pcommon.Address
+		ok   bool
+		err  bool
+	}{
+		{approved, true, false},
+		{unknown, false, false},
+		{failing, false, true},
+	} {
+		ok, err := IsPolyRelayer(polySdk, c.addr)
+		assert.Equal(t, c.err, err != nil, c.addr.ToHexString())
+		assert.Equal(t, c.ok, ok, c.addr.ToHexString())
+	}
+}
+
+func TestGetPolySideChain(t *testing.T) {
+	// serialize without the poly ledger
+	side_chain_manager.Test = true
+	defer func() { side_chain_manager.Test = false }()
+	sink := pcommon.NewZeroCopySink(nil)
+	registered := &side_chain_manager.SideChain{
+		ChainId:     79,
+		Router:      2,
+		Name:        "bsc",
+		CCMCAddress: []byte{0xee},
+		ExtraInfo:   []byte{0x01},
+	}
+	if !assert.NoError(t, registered.Serialization(sink)) {
+		return
+	}
+	srv, polySdk := newPolyStorageStub(map[string][]byte{
+		sideChainStorageKey(79): sink.Bytes(),
+		sideChainStorageKey(80): {0xff},
+		sideChainStorageKey(81): nil,
+	})
+	defer srv.Close()
+
+	for _, c := range []struct {
+		chainId   uint64
+		sideChain *side_chain_manager.SideChain
+		err       bool
+	}{
+		{79, registered, false},
+		{80, nil, true},
+		{81, nil, true},
+		{82, nil, false},
+	} {
+		sideChain, err := GetPolySideChain(polySdk, c.chainId)
+		assert.Equal(t, c.err, err != nil, "chain %d", c.chainId)
+		assert.Equal(t, c.sideChain, sideChain, "chain %d", c.chainId)
+	}
+}
+
+func TestNewPolySignerRefusesNewAccount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "polywallet")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	polySdk := sdk.NewPolySdk()
+	empty := filepath.Join(dir, "empty.dat")
+	if !assert.NoError(t, sdk.NewWallet(empty).Save()) {
+		return
+	}
+
+	for _, c := range []struct {
+		name    string
+		file    string
+		allow   bool
+		created bool
+	}{
+		{"missing wallet", filepath.Join(dir, "missing.dat"), false, false},
+		{"wallet without account", empty, false, false},
+		{"allowed missing wallet", filepath.Join(dir, "new.dat"), true, true},
+	} {
+		cfg := &config.PolyConfig{WalletFile: c.file, WalletPwd: "pwd", AllowNewWallet: c.allow}
+		signer, addr, err := NewPolySigner(cfg, polySdk)
+		if !c.created {
+			assert.Error(t, err, c.name)
+			assert.Nil(t, signer, c.name)
+			assert.Equal(t, pcommon.ADDRESS_EMPTY, addr, c.name)
+			if c.file != empty {
+				assert.False(t, pcommon.FileExisted(c.file), c.name)
+			}
+			continue
+		}
+		if !assert.NoError(t, err, c.name) {
+			continue
+		}
+		assert.NotEqual(t, pcommon.ADDRESS_EMPTY, addr, c.name)
+		// the account is saved and opened again as the default account
+		cfg.AllowNewWallet = false
+		_, reopened, err := NewPolySigner(cfg, polySdk)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, addr, reopened, c.name)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type Transfer struct {
+	From   common.Address
+	To     common.Address
+	Amount *big.Int
+}
+
+type accountDelta struct {
+	addr  common.Address
+	delta *big.Int
+}
+
+// PlanRebalance plans the transfers evening out the balances of the accounts.
+// Every transfer costs fee to its sender, transfers below minAmount are left out.
+func PlanRebalance(accs []common.Address, balances []*big.Int, fee, minAmount *big.Int) []*Transfer {
+	if len(accs) == 0 {
+		return nil
+	}
+	total := big.NewInt(0)
+	for _, v := range balances {
+		total.Add(total, v)
+	}
+	target := new(big.Int).Div(total, big.NewInt(int64(len(accs))))
+
+	var givers, takers []*accountDelta
+	for i, addr := range accs {
+		delta := new(big.Int).Sub(balances[i], target)
+		switch delta.Sign() {
+		case 1:
+			givers = append(givers, &accountDelta{addr, delta})
+		case -1:
+			takers = append(takers, &accountDelta{addr, delta.Neg(delta)})
+		}
+	}
+	byDelta := func(arr []*accountDelta) {
+		sort.SliceStable(arr, func(i, j int) bool { return arr[i].delta.Cmp(arr[j].delta) > 0 })
+	}
+	byDelta(givers)
+	byDelta(takers)
+
+	var plan []*Transfer
+	for i, j := 0, 0; i < len(givers) && j < len(takers); {
+		giver, taker := givers[i], takers[j]
+		if taker.delta.Sign() <= 0 || taker.delta.Cmp(minAmount) < 0 {
+			j++
+			continue
+		}
+		amount := new(big.Int).Sub(giver.delta, fee)
+		if amount.Sign() <= 0 || amount.Cmp(minAmount) < 0 {
+			i++
+			continue
+		}
+		if amount.Cmp(taker.delta) > 0 {
+			amount.Set(taker.delta)
+		}
+		plan = append(plan, &Transfer{From: giver.addr, To: taker.addr, Amount: amount})
+		giver.delta.Sub(giver.delta, new(big.Int).Add(amount, fee))
+		taker.delta.Sub(taker.delta, amount)
+	}
+	return plan
+}
@@ -0,0 +1,54 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanRebalance(t *testing.T) {
+	a, b, c := common.HexToAddress("0xa"), common.HexToAddress("0xb"), common.HexToAddress("0xc")
+	accs := []common.Address{a, b, c}
+	balances := func(v ...int64) []*big.Int {
+		arr := make([]*big.Int, len(v))
+		for i := range v {
+			arr[i] = big.NewInt(v[i])
+		}
+		return arr
+	}
+
+	// target is 400, a gives 200 to b and 300 to c paying a fee of 1 for each
+	plan := PlanRebalance(accs, balances(900, 200, 100), big.NewInt(1), big.NewInt(0))
+	assert.Equal(t, 2, len(plan))
+	assert.Equal(t, Transfer{From: a, To: c, Amount: big.NewInt(300)}, *plan[0])
+	assert.Equal(t, Transfer{From: a, To: b, Amount: big.NewInt(198)}, *plan[1])
+
+	// already even
+	assert.Empty(t, PlanRebalance(accs, balances(400, 400, 400), big.NewInt(1), big.NewInt(0)))
+
+	// differences below the minimum are left
+	plan = PlanRebalance(accs, balances(460, 410, 330), big.NewInt(1), big.NewInt(50))
+	assert.Equal(t, 1, len(plan))
+	assert.Equal(t, Transfer{From: a, To: c, Amount: big.NewInt(59)}, *plan[0])
+
+	// the surplus does not cover the fee
+	assert.Empty(t, PlanRebalance(accs[:2], balances(11, 9), big.NewInt(5), big.NewInt(0)))
+}
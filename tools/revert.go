@@ -0,0 +1,158 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// selector of Error(string), which solidity uses for require and revert messages
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+const revertPrefix = "execution reverted"
+
+// reverts of the cross chain manager which will never succeed by retrying
+var permanentReverts = []string{
+	"the transaction has been executed",
+	"this tx is not aiming at this network",
+	"invalid to contract or method",
+	"the passed in address is not a contract",
+	"verify header proof failed",
+	"merkleprove",
+	"the height of header is lower than current epoch start height",
+	"the nextbookkeeper of header is empty",
+	"nextbookers illegal",
+}
+
+// RevertError is returned by EthCall when the call is reverted by the contract
+type RevertError struct {
+	Reason string
+	Data   []byte
+}
+
+func (this *RevertError) Error() string {
+	if this.Reason == "" {
+		return revertPrefix
+	}
+	return revertPrefix + ": " + this.Reason
+}
+
+// Permanent tells whether the revert would happen again on retry
+func (this *RevertError) Permanent() bool {
+	return IsPermanentRevert(this.Reason)
+}
+
+type callArgs struct {
+	From common.Address `json:"from"`
+	To   common.Address `json:"to"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+type callReq struct {
+	JsonRpc string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	Id      uint          `json:"id"`
+}
+
+type callRsp struct {
+	JsonRPC string        `json:"jsonrpc"`
+	Result  hexutil.Bytes `json:"result,omitempty"`
+	Error   *jsonError    `json:"error,omitempty"`
+	Id      uint          `json:"id"`
+}
+
+// EthCall simulates the call on the latest block, a reverted call is returned
+// as *RevertError with the reason decoded from the revert data or the message
+func EthCall(url string, restClient *RestClient, from, to common.Address, data []byte) ([]byte, error) {
+	req := &callReq{
+		JsonRpc: "2.0",
+		Method:  "eth_call",
+		Params:  []interface{}{&callArgs{From: from, To: to, Data: data}, "latest"},
+		Id:      1,
+	}
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("EthCall: marshal req err: %s", err)
+	}
+	rspData, err := restClient.SendRestRequest(url, reqData)
+	if err != nil {
+		return nil, fmt.Errorf("EthCall err: %s", err)
+	}
+	rsp := &callRsp{}
+	err = json.Unmarshal(rspData, rsp)
+	if err != nil {
+		return nil, fmt.Errorf("EthCall, unmarshal resp err: %s", err)
+	}
+	if rsp.Error != nil {
+		if !strings.HasPrefix(rsp.Error.Message, revertPrefix) {
+			return nil, fmt.Errorf("EthCall, resp err: %s", rsp.Error.Message)
+		}
+		revert := &RevertError{Reason: strings.TrimPrefix(strings.TrimPrefix(rsp.Error.Message, revertPrefix), ": ")}
+		if s, ok := rsp.Error.Data.(string); ok {
+			if raw, err := hexutil.Decode(s); err == nil {
+				revert.Data = raw
+				if reason, err := DecodeRevertReason(raw); err == nil {
+					revert.Reason = reason
+				}
+			}
+		}
+		return nil, revert
+	}
+	// nodes before the revert reason support return the revert data as result
+	if reason, err := DecodeRevertReason(rsp.Result); err == nil {
+		return nil, &RevertError{Reason: reason, Data: rsp.Result}
+	}
+	return rsp.Result, nil
+}
+
+// DecodeRevertReason unpacks the string of Error(string) revert data
+func DecodeRevertReason(data []byte) (string, error) {
+	if len(data) < 4+64 || !bytes.Equal(data[:4], revertSelector) {
+		return "", fmt.Errorf("DecodeRevertReason - not an Error(string) revert")
+	}
+	data = data[4:]
+	offset := binary.BigEndian.Uint64(data[24:32])
+	if bytes.Count(data[:24], []byte{0}) != 24 || offset > uint64(len(data))-32 {
+		return "", fmt.Errorf("DecodeRevertReason - invalid offset")
+	}
+	size := binary.BigEndian.Uint64(data[offset+24 : offset+32])
+	if bytes.Count(data[offset:offset+24], []byte{0}) != 24 || size > uint64(len(data))-offset-32 {
+		return "", fmt.Errorf("DecodeRevertReason - invalid length")
+	}
+	return string(data[offset+32 : offset+32+size]), nil
+}
+
+// IsPermanentRevert classifies the revert reasons of the cross chain manager,
+// unknown reasons and failed signature checks (keepers of the contract may be
+// an epoch behind) are treated as transient
+func IsPermanentRevert(reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, v := range permanentReverts {
+		if strings.Contains(reason, v) {
+			return true
+		}
+	}
+	return false
+}
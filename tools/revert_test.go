@@ -0,0 +1,70 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// packed Error("the transaction has been executed!")
+const executedRevert = "0x08c379a0" +
+	"0000000000000000000000000000000000000000000000000000000000000020" +
+	"0000000000000000000000000000000000000000000000000000000000000022" +
+	"746865207472616e73616374696f6e20686173206265656e206578656375746564" +
+	"2100000000000000000000000000000000000000000000000000000000000000"
+
+func TestDecodeRevertReason(t *testing.T) {
+	reason, err := DecodeRevertReason(hexutil.MustDecode(executedRevert))
+	assert.NoError(t, err)
+	assert.Equal(t, "the transaction has been executed!", reason)
+
+	_, err = DecodeRevertReason(hexutil.MustDecode("0x08c379a0"))
+	assert.Error(t, err)
+	// offset pointing out of the data
+	_, err = DecodeRevertReason(hexutil.MustDecode("0x08c379a0" +
+		"00000000000000000000000000000000000000000000000000000000ffffffff" +
+		"0000000000000000000000000000000000000000000000000000000000000022"))
+	assert.Error(t, err)
+}
+
+func TestIsPermanentRevert(t *testing.T) {
+	assert.True(t, IsPermanentRevert("the transaction has been executed!"))
+	assert.True(t, IsPermanentRevert("This Tx is not aiming at this network!"))
+	assert.True(t, IsPermanentRevert("The height of header is lower than current epoch start height!"))
+	assert.False(t, IsPermanentRevert("Verify poly chain header signature failed!"))
+	assert.False(t, IsPermanentRevert(""))
+}
+
+func TestEthCallRevert(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted: the transaction has been executed!","data":"%s"}}`, executedRevert)
+	}))
+	defer srv.Close()
+
+	_, err := EthCall(srv.URL, NewRestClient(), common.Address{}, common.Address{}, nil)
+	revert, ok := err.(*RevertError)
+	assert.True(t, ok)
+	assert.Equal(t, "the transaction has been executed!", revert.Reason)
+	assert.True(t, revert.Permanent())
+}
@@ -0,0 +1,225 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ontio/ontology-crypto/keypair"
+	s "github.com/ontio/ontology-crypto/signature"
+	"github.com/polynetwork/bsc-relayer/config"
+	pcommon "github.com/polynetwork/poly/common"
+	ptypes "github.com/polynetwork/poly/core/types"
+)
+
+const defaultSignTimeout = time.Minute
+
+// Signer signs the bsc transactions of the relayer accounts. EthKeyStore signs
+// with keys decrypted in process, RemoteSigner asks an external signer.
+type Signer interface {
+	GetAccounts() []accounts.Account
+	SignTransaction(tx *types.Transaction, acc accounts.Account) (*types.Transaction, error)
+	GetChainId() uint64
+}
+
+// SignTxArgs are the arguments of account_signTransaction, as clef takes them
+type SignTxArgs struct {
+	From     common.MixedcaseAddress  `json:"from"`
+	To       *common.MixedcaseAddress `json:"to"`
+	Gas      hexutil.Uint64           `json:"gas"`
+	GasPrice hexutil.Big              `json:"gasPrice"`
+	Value    hexutil.Big              `json:"value"`
+	Nonce    hexutil.Uint64           `json:"nonce"`
+	Data     *hexutil.Bytes           `json:"data"`
+}
+
+type SignTxResponse struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+func NewSignTxArgs(tx *types.Transaction, from common.Address) *SignTxArgs {
+	data := hexutil.Bytes(tx.Data())
+	args := &SignTxArgs{
+		From:     common.NewMixedcaseAddress(from),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     &data,
+	}
+	if tx.To() != nil {
+		to := common.NewMixedcaseAddress(*tx.To())
+		args.To = &to
+	}
+	return args
+}
+
+// Transaction is the unsigned tx of the arguments
+func (this *SignTxArgs) Transaction() *types.Transaction {
+	var data []byte
+	if this.Data != nil {
+		data = *this.Data
+	}
+	if this.To == nil {
+		return types.NewContractCreation(uint64(this.Nonce), (*big.Int)(&this.Value), uint64(this.Gas), (*big.Int)(&this.GasPrice), data)
+	}
+	return types.NewTransaction(uint64(this.Nonce), this.To.Address(), (*big.Int)(&this.Value), uint64(this.Gas), (*big.Int)(&this.GasPrice), data)
+}
+
+// RemoteSigner signs with an external signer speaking the account_ API of
+// clef, the keys never enter the relayer process
+type RemoteSigner struct {
+	client   *rpc.Client
+	url      string
+	timeout  time.Duration
+	chainId  *big.Int
+	accounts []accounts.Account
+}
+
+func NewRemoteSigner(cfg *config.RemoteSignerConfig, chainId *big.Int) (*RemoteSigner, error) {
+	client, err := rpc.Dial(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("NewRemoteSigner - cannot dial %s: %v", cfg.URL, err)
+	}
+	this := &RemoteSigner{client: client, url: cfg.URL, timeout: time.Duration(cfg.Timeout) * time.Second, chainId: chainId}
+	if this.timeout <= 0 {
+		this.timeout = defaultSignTimeout
+	}
+	var addrs []common.Address
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	if err = client.CallContext(ctx, &addrs, "account_list"); err != nil {
+		return nil, fmt.Errorf("NewRemoteSigner - account_list of %s: %v", cfg.URL, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("NewRemoteSigner - signer %s has no account", cfg.URL)
+	}
+	for _, v := range addrs {
+		this.accounts = append(this.accounts, accounts.Account{Address: v})
+	}
+	return this, nil
+}
+
+func (this *RemoteSigner) GetAccounts() []accounts.Account {
+	return this.accounts
+}
+
+func (this *RemoteSigner) GetChainId() uint64 {
+	return this.chainId.Uint64()
+}
+
+// SignTransaction checks that the signer signed the very tx asked, by acc and
+// for the chain of the relayer
+func (this *RemoteSigner) SignTransaction(tx *types.Transaction, acc accounts.Account) (*types.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	rsp := &SignTxResponse{}
+	if err := this.client.CallContext(ctx, rsp, "account_signTransaction", NewSignTxArgs(tx, acc.Address)); err != nil {
+		return nil, fmt.Errorf("account_signTransaction of %s: %v", this.url, err)
+	}
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(rsp.Raw, signed); err != nil {
+		return nil, fmt.Errorf("invalid tx from signer %s: %v", this.url, err)
+	}
+	signer := types.NewEIP155Signer(this.chainId)
+	if signer.Hash(signed) != signer.Hash(tx) {
+		return nil, fmt.Errorf("signer %s signed another tx than %s", this.url, signer.Hash(tx).Hex())
+	}
+	from, err := types.Sender(signer, signed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from signer %s: %v", this.url, err)
+	}
+	if from != acc.Address {
+		return nil, fmt.Errorf("signer %s signed with %s instead of %s", this.url, from.Hex(), acc.Address.Hex())
+	}
+	return signed, nil
+}
+
+// RemotePolySigner signs poly transactions with an external signer through
+// poly_publicKey and poly_sign, it implements the Signer of the poly sdk
+type RemotePolySigner struct {
+	client  *rpc.Client
+	url     string
+	timeout time.Duration
+	pub     keypair.PublicKey
+}
+
+func NewRemotePolySigner(cfg *config.RemoteSignerConfig) (*RemotePolySigner, error) {
+	client, err := rpc.Dial(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("NewRemotePolySigner - cannot dial %s: %v", cfg.URL, err)
+	}
+	this := &RemotePolySigner{client: client, url: cfg.URL, timeout: time.Duration(cfg.Timeout) * time.Second}
+	if this.timeout <= 0 {
+		this.timeout = defaultSignTimeout
+	}
+	var raw hexutil.Bytes
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	if err = client.CallContext(ctx, &raw, "poly_publicKey"); err != nil {
+		return nil, fmt.Errorf("NewRemotePolySigner - poly_publicKey of %s: %v", cfg.URL, err)
+	}
+	if this.pub, err = keypair.DeserializePublicKey(raw); err != nil {
+		return nil, fmt.Errorf("NewRemotePolySigner - invalid public key from %s: %v", cfg.URL, err)
+	}
+	return this, nil
+}
+
+func (this *RemotePolySigner) Address() pcommon.Address {
+	return ptypes.AddressFromPubKey(this.pub)
+}
+
+// Sign returns the serialized signature, checked against the public key
+func (this *RemotePolySigner) Sign(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	var raw hexutil.Bytes
+	if err := this.client.CallContext(ctx, &raw, "poly_sign", hexutil.Bytes(data)); err != nil {
+		return nil, fmt.Errorf("poly_sign of %s: %v", this.url, err)
+	}
+	sig, err := s.Deserialize(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from signer %s: %v", this.url, err)
+	}
+	if !s.Verify(this.pub, data, sig) {
+		return nil, fmt.Errorf("signature from signer %s does not match its public key", this.url)
+	}
+	return raw, nil
+}
+
+func (this *RemotePolySigner) GetPublicKey() keypair.PublicKey {
+	return this.pub
+}
+
+// GetPrivateKey is nil, the key stays in the signer
+func (this *RemotePolySigner) GetPrivateKey() keypair.PrivateKey {
+	return nil
+}
+
+func (this *RemotePolySigner) GetSigScheme() s.SignatureScheme {
+	return s.SHA256withECDSA
+}
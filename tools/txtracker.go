@@ -0,0 +1,446 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/polynetwork/bsc-relayer/config"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/bsc-relayer/log"
+	"github.com/polynetwork/poly/common"
+)
+
+const (
+	defaultSpeedupDelay = 180
+	defaultBumpPercent  = 10
+	defaultMaxSpeedups  = 5
+)
+
+var (
+	ErrTxCancelled = errors.New("transaction is cancelled by a self transfer")
+	ErrTxReplaced  = errors.New("nonce is used by an unknown transaction")
+	ErrTxNotFound  = errors.New("no tracked transaction with this nonce")
+)
+
+// TrackedTx is an outstanding transaction of a relayer account. Every
+// replacement sent for the nonce is kept, since any of them may get mined.
+type TrackedTx struct {
+	Label      string
+	Tx         *types.Transaction
+	Hashes     []ethcommon.Hash
+	CancelHash ethcommon.Hash
+	SentAt     time.Time
+	BumpedAt   time.Time
+	Bumps      uint64
+
+	done    chan struct{}
+	receipt *types.Receipt
+	err     error
+}
+
+func (this *TrackedTx) Serialization(sink *common.ZeroCopySink) error {
+	raw, err := rlp.EncodeToBytes(this.Tx)
+	if err != nil {
+		return err
+	}
+	sink.WriteString(this.Label)
+	sink.WriteVarBytes(raw)
+	sink.WriteUint64(uint64(len(this.Hashes)))
+	for _, h := range this.Hashes {
+		sink.WriteVarBytes(h.Bytes())
+	}
+	sink.WriteVarBytes(this.CancelHash.Bytes())
+	sink.WriteUint64(uint64(this.SentAt.Unix()))
+	sink.WriteUint64(uint64(this.BumpedAt.Unix()))
+	sink.WriteUint64(this.Bumps)
+	return nil
+}
+
+func (this *TrackedTx) Deserialization(source *common.ZeroCopySource) error {
+	label, eof := source.NextString()
+	if eof {
+		return fmt.Errorf("TrackedTx deserialize label error")
+	}
+	raw, eof := source.NextVarBytes()
+	if eof {
+		return fmt.Errorf("TrackedTx deserialize tx error")
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		return fmt.Errorf("TrackedTx decode tx error: %v", err)
+	}
+	n, eof := source.NextUint64()
+	if eof {
+		return fmt.Errorf("TrackedTx deserialize hashes error")
+	}
+	hashes := make([]ethcommon.Hash, 0, n)
+	for i := uint64(0); i < n; i++ {
+		h, eof := source.NextVarBytes()
+		if eof {
+			return fmt.Errorf("TrackedTx deserialize hash error")
+		}
+		hashes = append(hashes, ethcommon.BytesToHash(h))
+	}
+	cancelHash, eof := source.NextVarBytes()
+	if eof {
+		return fmt.Errorf("TrackedTx deserialize cancel hash error")
+	}
+	sentAt, eof := source.NextUint64()
+	if eof {
+		return fmt.Errorf("TrackedTx deserialize sent time error")
+	}
+	bumpedAt, eof := source.NextUint64()
+	if eof {
+		return fmt.Errorf("TrackedTx deserialize bumped time error")
+	}
+	bumps, eof := source.NextUint64()
+	if eof {
+		return fmt.Errorf("TrackedTx deserialize bumps error")
+	}
+	this.Label = label
+	this.Tx = tx
+	this.Hashes = hashes
+	this.CancelHash = ethcommon.BytesToHash(cancelHash)
+	this.SentAt = time.Unix(int64(sentAt), 0)
+	this.BumpedAt = time.Unix(int64(bumpedAt), 0)
+	this.Bumps = bumps
+	return nil
+}
+
+// TxTracker watches the outstanding transactions of one account. A tx not
+// mined after SpeedupDelay is replaced by the same tx at a higher price, and
+// after CancelAfter by a zero value self transfer with the same nonce.
+type TxTracker struct {
+	acc          accounts.Account
+	keyStore     Signer
+	ethClient    *ethclient.Client
+	nonceManager *NonceManager
+	gasOracle    *BoundedGasOracle
+	db           *db.BoltDB
+
+	speedupDelay time.Duration
+	bumpPercent  uint64
+	maxSpeedups  uint64
+	cancelAfter  time.Duration
+
+	lock sync.Mutex
+	txs  map[uint64]*TrackedTx
+}
+
+func NewTxTracker(acc accounts.Account, ks Signer, ethClient *ethclient.Client, nonceManager *NonceManager,
+	gasOracle *BoundedGasOracle, boltDB *db.BoltDB, cfg *config.TxTrackerConfig) (*TxTracker, error) {
+	if cfg == nil {
+		cfg = &config.TxTrackerConfig{}
+	}
+	this := &TxTracker{
+		acc:          acc,
+		keyStore:     ks,
+		ethClient:    ethClient,
+		nonceManager: nonceManager,
+		gasOracle:    gasOracle,
+		db:           boltDB,
+		speedupDelay: time.Duration(cfg.SpeedupDelay) * time.Second,
+		bumpPercent:  cfg.BumpPercent,
+		maxSpeedups:  uint64(cfg.MaxSpeedups),
+		cancelAfter:  time.Duration(cfg.CancelAfter) * time.Second,
+		txs:          make(map[uint64]*TrackedTx),
+	}
+	if this.speedupDelay == 0 {
+		this.speedupDelay = defaultSpeedupDelay * time.Second
+	}
+	if this.bumpPercent < defaultBumpPercent {
+		this.bumpPercent = defaultBumpPercent
+	}
+	if cfg.MaxSpeedups == 0 {
+		this.maxSpeedups = defaultMaxSpeedups
+	}
+
+	stored, err := boltDB.GetAllTrackedTx(acc.Address.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	for nonce, raw := range stored {
+		t := &TrackedTx{}
+		if err := t.Deserialization(common.NewZeroCopySource(raw)); err != nil {
+			log.Errorf("NewTxTracker - failed to load tx of %s with nonce %d: %v", acc.Address.Hex(), nonce, err)
+			continue
+		}
+		t.done = make(chan struct{})
+		this.txs[nonce] = t
+		log.Infof("NewTxTracker - account %s still has tx %s (nonce: %d, label: %s) outstanding",
+			acc.Address.Hex(), t.Tx.Hash().String(), nonce, t.Label)
+	}
+	return this, nil
+}
+
+// Track starts watching a sent transaction, the returned handle waits for it
+// even if it gets resolved before Wait is called. A tx tracked earlier with the
+// same nonce is replaced.
+func (this *TxTracker) Track(tx *types.Transaction, label string) *TrackedTx {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := time.Now()
+	t := &TrackedTx{
+		Label:    label,
+		Tx:       tx,
+		Hashes:   []ethcommon.Hash{tx.Hash()},
+		SentAt:   now,
+		BumpedAt: now,
+		done:     make(chan struct{}),
+	}
+	if old, ok := this.txs[tx.Nonce()]; ok {
+		old.err = ErrTxReplaced
+		close(old.done)
+	}
+	this.txs[tx.Nonce()] = t
+	this.save(tx.Nonce(), t)
+	return t
+}
+
+// Wait blocks until a transaction with the nonce of the tracked tx is mined. It
+// returns ErrTxCancelled if the mined one is the cancelling self transfer.
+func (this *TrackedTx) Wait() (*types.Receipt, error) {
+	<-this.done
+	return this.receipt, this.err
+}
+
+// Pending returns the number of outstanding transactions
+func (this *TxTracker) Pending() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return len(this.txs)
+}
+
+func (this *TxTracker) Monitor() {
+	ticker := time.NewTicker(config.TX_TRACKER_INTERVAL)
+	for range ticker.C {
+		this.check()
+	}
+}
+
+func (this *TxTracker) check() {
+	this.lock.Lock()
+	nonces := make([]uint64, 0, len(this.txs))
+	for nonce := range this.txs {
+		nonces = append(nonces, nonce)
+	}
+	this.lock.Unlock()
+	if len(nonces) == 0 {
+		return
+	}
+
+	mined, err := this.ethClient.NonceAt(context.Background(), this.acc.Address, nil)
+	if err != nil {
+		log.Errorf("TxTracker - failed to get nonce of %s: %v", this.acc.Address.Hex(), err)
+		return
+	}
+	for _, nonce := range nonces {
+		this.lock.Lock()
+		t, ok := this.txs[nonce]
+		this.lock.Unlock()
+		if !ok {
+			continue
+		}
+		receipt := this.findReceipt(t)
+		if receipt == nil && nonce < mined {
+			// nonce moved on before our receipt showed up, look once more
+			receipt = this.findReceipt(t)
+		}
+		switch {
+		case receipt != nil:
+			if receipt.TxHash == t.CancelHash {
+				this.resolve(nonce, t, receipt, ErrTxCancelled)
+			} else {
+				this.resolve(nonce, t, receipt, nil)
+			}
+		case nonce < mined:
+			this.resolve(nonce, t, nil, ErrTxReplaced)
+		case this.cancelAfter > 0 && t.CancelHash == (ethcommon.Hash{}) && time.Since(t.SentAt) > this.cancelAfter:
+			if _, err := this.Cancel(nonce, nil); err != nil {
+				log.Errorf("TxTracker - failed to cancel tx of %s with nonce %d: %v", this.acc.Address.Hex(), nonce, err)
+			}
+		case t.Bumps < this.maxSpeedups && time.Since(t.BumpedAt) > this.speedupDelay:
+			if t.CancelHash != (ethcommon.Hash{}) {
+				_, err = this.Cancel(nonce, nil)
+			} else {
+				_, err = this.SpeedUp(nonce, nil)
+			}
+			if err != nil {
+				log.Errorf("TxTracker - failed to speed up tx of %s with nonce %d: %v", this.acc.Address.Hex(), nonce, err)
+			}
+		}
+	}
+}
+
+func (this *TxTracker) findReceipt(t *TrackedTx) *types.Receipt {
+	for _, hash := range t.Hashes {
+		receipt, err := this.ethClient.TransactionReceipt(context.Background(), hash)
+		if err == nil && receipt != nil {
+			return receipt
+		}
+		if err != nil && err != ethereum.NotFound {
+			log.Debugf("TxTracker - failed to get receipt of %s: %v", hash.String(), err)
+		}
+	}
+	return nil
+}
+
+func (this *TxTracker) resolve(nonce uint64, t *TrackedTx, receipt *types.Receipt, err error) {
+	this.lock.Lock()
+	delete(this.txs, nonce)
+	this.lock.Unlock()
+	if dbErr := this.db.DeleteTrackedTx(this.acc.Address.Bytes(), nonce); dbErr != nil {
+		log.Errorf("TxTracker - failed to delete tx of %s with nonce %d: %v", this.acc.Address.Hex(), nonce, dbErr)
+	}
+	this.nonceManager.ConfirmNonce(this.acc.Address, nonce)
+	if err != nil {
+		log.Errorf("TxTracker - tx of %s with nonce %d (label: %s) is not mined: %v", this.acc.Address.Hex(), nonce, t.Label, err)
+	}
+	t.receipt = receipt
+	t.err = err
+	close(t.done)
+}
+
+// SpeedUp replaces the tx with the nonce by the same tx at a higher price,
+// the price is bumped from the last one if gasPrice is nil
+func (this *TxTracker) SpeedUp(nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	this.lock.Lock()
+	t, ok := this.txs[nonce]
+	this.lock.Unlock()
+	if !ok {
+		return nil, ErrTxNotFound
+	}
+	price, err := this.replacementPrice(t.Tx.GasPrice(), gasPrice)
+	if err != nil {
+		return nil, err
+	}
+	tx := types.NewTransaction(nonce, *t.Tx.To(), t.Tx.Value(), t.Tx.Gas(), price, t.Tx.Data())
+	signed, err := this.send(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	this.lock.Lock()
+	t.Tx = signed
+	t.Hashes = append(t.Hashes, signed.Hash())
+	t.BumpedAt = time.Now()
+	t.Bumps++
+	this.save(nonce, t)
+	this.lock.Unlock()
+	log.Infof("TxTracker - speed up tx of %s with nonce %d (label: %s): %s at price %s",
+		this.acc.Address.Hex(), nonce, t.Label, signed.Hash().String(), price.String())
+	return signed, nil
+}
+
+// Cancel replaces the tx with the nonce by a zero value self transfer. The
+// nonce does not have to be tracked, which frees nonces stuck by other tools.
+func (this *TxTracker) Cancel(nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	this.lock.Lock()
+	t, ok := this.txs[nonce]
+	this.lock.Unlock()
+
+	var last *big.Int
+	if ok {
+		last = t.Tx.GasPrice()
+	}
+	price, err := this.replacementPrice(last, gasPrice)
+	if err != nil {
+		return nil, err
+	}
+	tx := types.NewTransaction(nonce, this.acc.Address, big.NewInt(0), TransferGasLimit, price, nil)
+	signed, err := this.send(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	this.lock.Lock()
+	if !ok {
+		now := time.Now()
+		t = &TrackedTx{Label: "cancel", SentAt: now, done: make(chan struct{})}
+		this.txs[nonce] = t
+	}
+	t.Tx = signed
+	t.Hashes = append(t.Hashes, signed.Hash())
+	t.CancelHash = signed.Hash()
+	t.BumpedAt = time.Now()
+	t.Bumps++
+	this.save(nonce, t)
+	this.lock.Unlock()
+	log.Infof("TxTracker - cancel tx of %s with nonce %d (label: %s): %s at price %s",
+		this.acc.Address.Hex(), nonce, t.Label, signed.Hash().String(), price.String())
+	return signed, nil
+}
+
+// replacementPrice is the given price, or the last price bumped by the
+// configured percent and not lower than the current suggestion
+func (this *TxTracker) replacementPrice(last, gasPrice *big.Int) (*big.Int, error) {
+	if gasPrice != nil {
+		return gasPrice, nil
+	}
+	price, err := this.gasOracle.SuggestGasPrice(context.Background())
+	if err != nil && !errors.Is(err, ErrGasPriceTooHigh) {
+		return nil, err
+	}
+	if last != nil {
+		bumped := new(big.Int).Mul(last, new(big.Int).SetUint64(100+this.bumpPercent))
+		bumped.Quo(bumped, big.NewInt(100)).Add(bumped, big.NewInt(1))
+		if price == nil || price.Cmp(bumped) < 0 {
+			price = bumped
+		}
+	}
+	if max := this.gasOracle.MaxPrice(); max != nil && price.Cmp(max) > 0 {
+		return nil, fmt.Errorf("%w: replacement needs %s, max %s", ErrGasPriceTooHigh, price.String(), max.String())
+	}
+	return price, nil
+}
+
+func (this *TxTracker) send(tx *types.Transaction) (*types.Transaction, error) {
+	signed, err := this.keyStore.SignTransaction(tx, this.acc)
+	if err != nil {
+		return nil, err
+	}
+	err = this.ethClient.SendTransaction(context.Background(), signed)
+	if err != nil && !strings.Contains(err.Error(), "already known") {
+		return nil, err
+	}
+	return signed, nil
+}
+
+func (this *TxTracker) save(nonce uint64, t *TrackedTx) {
+	sink := common.NewZeroCopySink(nil)
+	if err := t.Serialization(sink); err != nil {
+		log.Errorf("TxTracker - failed to serialize tx of %s with nonce %d: %v", this.acc.Address.Hex(), nonce, err)
+		return
+	}
+	if err := this.db.PutTrackedTx(this.acc.Address.Bytes(), nonce, sink.Bytes()); err != nil {
+		log.Errorf("TxTracker - failed to save tx of %s with nonce %d: %v", this.acc.Address.Hex(), nonce, err)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+* Copyright (C) 2020 The poly network Authors
+* This file is part of The poly network library.
+*
+* The poly network is free software: you can redistribute it and/or modify
+* it under the terms of the GNU Lesser General Public License as published by
+* the Free Software Foundation, either version 3 of the License, or
+* (at your option) any later version.
+*
+* The poly network is distributed in the hope that it will be useful,
+* but WITHOUT ANY WARRANTY; without even the implied warranty of
+* MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+* GNU Lesser General Public License for more details.
+* You should have received a copy of the GNU Lesser General Public License
+* along with The poly network . If not, see <http://www.gnu.org/licenses/>.
+ */
+package tools
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/polynetwork/bsc-relayer/db"
+	"github.com/polynetwork/poly/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackedTxSerialization(t *testing.T) {
+	tx := types.NewTransaction(7, ethcommon.HexToAddress("0x01"), big.NewInt(0), 21000, big.NewInt(5e9), []byte{1, 2, 3})
+	tracked := &TrackedTx{
+		Label:      "poly_hash",
+		Tx:         tx,
+		Hashes:     []ethcommon.Hash{ethcommon.HexToHash("0x02"), tx.Hash()},
+		CancelHash: tx.Hash(),
+		SentAt:     time.Unix(1600000000, 0),
+		BumpedAt:   time.Unix(1600000100, 0),
+		Bumps:      2,
+	}
+	sink := common.NewZeroCopySink(nil)
+	assert.NoError(t, tracked.Serialization(sink))
+
+	res := &TrackedTx{}
+	assert.NoError(t, res.Deserialization(common.NewZeroCopySource(sink.Bytes())))
+	assert.Equal(t, tracked.Label, res.Label)
+	assert.Equal(t, tx.Hash(), res.Tx.Hash())
+	assert.Equal(t, tracked.Hashes, res.Hashes)
+	assert.Equal(t, tracked.CancelHash, res.CancelHash)
+	assert.True(t, tracked.SentAt.Equal(res.SentAt))
+	assert.True(t, tracked.BumpedAt.Equal(res.BumpedAt))
+	assert.Equal(t, tracked.Bumps, res.Bumps)
+}
+
+func TestTrackedTxWait(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracker")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	boltDB, err := db.NewBoltDB(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer boltDB.Close()
+	tracker := &TxTracker{
+		acc:          accounts.Account{Address: ethcommon.HexToAddress("0x01")},
+		db:           boltDB,
+		nonceManager: NewNonceManager(nil, boltDB),
+		txs:          make(map[uint64]*TrackedTx),
+	}
+
+	// a tx resolved before Wait is called still returns its receipt
+	tx := types.NewTransaction(7, ethcommon.HexToAddress("0x02"), big.NewInt(0), 21000, big.NewInt(5e9), nil)
+	tracked := tracker.Track(tx, "poly_hash")
+	receipt := &types.Receipt{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful}
+	tracker.resolve(7, tracked, receipt, nil)
+	assert.Equal(t, 0, tracker.Pending())
+	res, err := tracked.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, receipt, res)
+
+	// tracking the nonce again releases the waiter of the old tx
+	old := tracker.Track(tx, "poly_hash")
+	tracker.Track(types.NewTransaction(7, ethcommon.HexToAddress("0x01"), big.NewInt(0), 21000, big.NewInt(6e9), nil), "nonce gap")
+	_, err = old.Wait()
+	assert.Equal(t, ErrTxReplaced, err)
+	assert.Equal(t, 1, tracker.Pending())
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
@@ -190,6 +191,74 @@ func GetProof(url string, contractAddress string, key string, blockheight string
 	return result, nil
 }
 
+type baseFeeRsp struct {
+	JsonRPC string `json:"jsonrpc"`
+	Result  *struct {
+		BaseFee *hexutil.Big `json:"baseFeePerGas"`
+	} `json:"result,omitempty"`
+	Error *jsonError `json:"error,omitempty"`
+	Id    uint       `json:"id"`
+}
+
+func GetBaseFee(url string, restClient *RestClient) (*big.Int, error) {
+	req := &blockReq{
+		JsonRpc: "2.0",
+		Method:  "eth_getBlockByNumber",
+		Params:  []interface{}{"latest", false},
+		Id:      1,
+	}
+	reqdata, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("GetBaseFee: marshal req err: %s", err)
+	}
+	rspdata, err := restClient.SendRestRequest(url, reqdata)
+	if err != nil {
+		return nil, fmt.Errorf("GetBaseFee err: %s", err)
+	}
+	rsp := &baseFeeRsp{}
+	err = json.Unmarshal(rspdata, rsp)
+	if err != nil {
+		return nil, fmt.Errorf("GetBaseFee, unmarshal resp err: %s", err)
+	}
+	if rsp.Error != nil {
+		return nil, fmt.Errorf("GetBaseFee, unmarshal resp err: %s", rsp.Error.Message)
+	}
+	if rsp.Result == nil || rsp.Result.BaseFee == nil {
+		return nil, fmt.Errorf("GetBaseFee, no base fee in latest block, chain does not support EIP-1559")
+	}
+	return rsp.Result.BaseFee.ToInt(), nil
+}
+
+func GetMaxPriorityFee(url string, restClient *RestClient) (*big.Int, error) {
+	req := &heightReq{
+		JsonRpc: "2.0",
+		Method:  "eth_maxPriorityFeePerGas",
+		Params:  make([]string, 0),
+		Id:      1,
+	}
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("GetMaxPriorityFee: marshal req err: %s", err)
+	}
+	rspData, err := restClient.SendRestRequest(url, reqData)
+	if err != nil {
+		return nil, fmt.Errorf("GetMaxPriorityFee err: %s", err)
+	}
+	rsp := &heightRsp{}
+	err = json.Unmarshal(rspData, rsp)
+	if err != nil {
+		return nil, fmt.Errorf("GetMaxPriorityFee, unmarshal resp err: %s", err)
+	}
+	if rsp.Error != nil {
+		return nil, fmt.Errorf("GetMaxPriorityFee, unmarshal resp err: %s", rsp.Error.Message)
+	}
+	tip, err := hexutil.DecodeBig(rsp.Result)
+	if err != nil {
+		return nil, fmt.Errorf("GetMaxPriorityFee, parse resp tip %s failed", rsp.Result)
+	}
+	return tip, nil
+}
+
 func EncodeBigInt(b *big.Int) string {
 	if b.Uint64() == 0 {
 		return "00"
@@ -291,7 +360,6 @@ func GetExplorerUrl(chainId uint64) string {
 
 
 func GetEthNoCompressKey(key keypair.PublicKey) []byte {
-	var buf bytes.Buffer
 	switch t := key.(type) {
 	case *ec.PublicKey:
 		return crypto.FromECDSAPub(t.PublicKey)
@@ -300,5 +368,4 @@ func GetEthNoCompressKey(key keypair.PublicKey) []byte {
 	default:
 		panic("err")
 	}
-	return buf.Bytes()
 }
\ No newline at end of file